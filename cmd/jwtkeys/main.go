@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/joho/godotenv"
+)
+
+const usage = `Usage: jwtkeys [-dir DIR] <command> [args]
+
+Commands:
+  list                       show every key and its status
+  generate [-alg ALG]        create a new verify key (RS256, ES256, ES384, EdDSA)
+  promote KID                make KID the signing key, demoting the current one
+  retire [-force] [-max-age DURATION] KID
+                             stop accepting KID once its tokens have expired
+`
+
+func main() {
+	godotenv.Load(".env")
+
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	dir := flag.String("dir", os.Getenv("JWT_KEYS_DIR"), "keys directory")
+	flag.Parse()
+
+	if *dir == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := jwt.ReadManifest(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	args := flag.Args()[1:]
+	retired := ""
+	switch flag.Arg(0) {
+	case "list":
+		list(m)
+		return
+	case "generate":
+		err = generate(*dir, m, args)
+	case "promote":
+		if len(args) != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = m.Promote(args[0])
+	case "retire":
+		retired, err = retire(m, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := m.Write(*dir); err != nil {
+		log.Fatal(err)
+	}
+
+	// The key file goes only once the manifest no longer lists it, so a
+	// failed write never leaves the server looking for a missing file.
+	if retired != "" {
+		err := os.Remove(filepath.Join(*dir, retired+".pem"))
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatal(err)
+		}
+	}
+
+	list(m)
+}
+
+func list(m *jwt.Manifest) {
+	for _, entry := range m.Keys {
+		fmt.Printf("%-32s %-6s %-8s created %s\n", entry.Id, entry.Alg, entry.Status, entry.CreatedAt.Format(time.RFC3339))
+	}
+}
+
+func generate(dir string, m *jwt.Manifest, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	alg := fs.String("alg", "ES256", "signing algorithm")
+	fs.Parse(args)
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	id := now.Format("20060102") + "-" + hex.EncodeToString(suffix)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	if err := jwt.GenerateKeyFile(dir, id, *alg); err != nil {
+		return err
+	}
+
+	// The first key in an empty directory signs immediately; later keys
+	// start as verify-only so they reach every JWKS cache before promotion.
+	status := jwt.KeyVerify
+	if m.Active() == nil {
+		status = jwt.KeyActive
+	}
+
+	m.Keys = append(m.Keys, jwt.ManifestEntry{
+		Id:        id,
+		Alg:       *alg,
+		Status:    status,
+		CreatedAt: now,
+	})
+
+	return nil
+}
+
+// retire marks a key retired in m and returns its id, so the key file can
+// be removed after the manifest is written.
+func retire(m *jwt.Manifest, args []string) (string, error) {
+	fs := flag.NewFlagSet("retire", flag.ExitOnError)
+	force := fs.Bool("force", false, "retire even if tokens may still be valid")
+	maxAge := fs.Duration("max-age", time.Hour, "longest lifetime of a token signed by the key")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	id := fs.Arg(0)
+	if err := m.Retire(id, *maxAge, *force); err != nil {
+		return "", err
+	}
+
+	return id, nil
+}
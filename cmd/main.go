@@ -1,20 +1,29 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-
-	// "time"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Hdeee1/go-register-login-profile/internal/delivery/http"
 	"github.com/Hdeee1/go-register-login-profile/internal/delivery/http/middleware"
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
 	repository "github.com/Hdeee1/go-register-login-profile/internal/repository/mysql"
 	"github.com/Hdeee1/go-register-login-profile/internal/usecase"
 	"github.com/Hdeee1/go-register-login-profile/pkg/database"
 	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/pwned"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/joho/godotenv"
 )
 
@@ -33,9 +42,212 @@ func main() {
 		log.Fatal("Failed to create user repository")
 	}
 
-	useCase := usecase.NewUserUsecase(repo)
-	blackList := jwt.NewTokenBlacklist()
+	refreshTokenRepo, err := repository.NewRefreshTokenRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create refresh token repository")
+	}
+
+	sessionRepo, err := repository.NewSessionRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create session repository")
+	}
+
+	mfaRepo, err := repository.NewMFARepository(db)
+	if err != nil {
+		log.Fatal("Failed to create mfa repository")
+	}
+
+	mfaKey, err := hex.DecodeString(os.Getenv("MFA_ENCRYPTION_KEY"))
+	if err != nil || len(mfaKey) != 32 {
+		log.Fatal("MFA_ENCRYPTION_KEY must be 32 bytes, hex encoded")
+	}
+
+	webauthnRepo, err := repository.NewWebAuthnRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create webauthn repository")
+	}
+
+	magicLinkRepo, err := repository.NewMagicLinkRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create magic link repository")
+	}
+
+	verificationRepo, err := repository.NewEmailVerificationRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create email verification repository")
+	}
+
+	emailChangeRepo, err := repository.NewEmailChangeRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create email change repository")
+	}
+
+	accessKeys, err := newAccessKeySet()
+	if err != nil {
+		log.Fatalf("Failed to load signing keys. Error: %s", err.Error())
+	}
+	refreshKeys := jwt.NewHMACKeySet(os.Getenv("JWT_REFRESH_SECRET"), envList("JWT_REFRESH_PREVIOUS_SECRETS")...)
+
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost:8080"
+	}
+
+	audience := envList("JWT_AUDIENCE")
+	if len(audience) == 0 {
+		audience = []string{issuer}
+	}
+
+	accessToken := jwt.TokenConfig{
+		Keys:     accessKeys,
+		Type:     jwt.AccessToken,
+		Issuer:   issuer,
+		Audience: audience,
+		Expiry:   time.Hour,
+	}
+
+	refreshToken := jwt.TokenConfig{
+		Keys:     refreshKeys,
+		Type:     jwt.RefreshToken,
+		Issuer:   issuer,
+		Audience: []string{issuer},
+		Expiry:   24 * time.Hour,
+	}
+
+	blackList, err := newBlacklist(db)
+	if err != nil {
+		log.Fatalf("Failed to create token blacklist. Error: %s", err.Error())
+	}
+
+	mail, err := newMailer()
+	if err != nil {
+		log.Fatalf("Failed to create mailer. Error: %s", err.Error())
+	}
+	mailQueue := mailer.NewQueue(mail, 100, 2, 5, 2*time.Second)
+	defer mailQueue.Close()
+
+	passwordReset, err := newPasswordResetConfig()
+	if err != nil {
+		log.Fatalf("Failed to configure password reset. Error: %s", err.Error())
+	}
+
+	// With enumeration protection on, responses no longer reveal whether an
+	// email or username has an account.
+	hideAccounts := os.Getenv("ENUMERATION_PROTECTION") == "true"
+
+	hasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing. Error: %s", err.Error())
+	}
+
+	var breached pwned.Checker
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		threshold := 1
+		if env := os.Getenv("BREACHED_PASSWORD_THRESHOLD"); env != "" {
+			if threshold, err = strconv.Atoi(env); err != nil {
+				log.Fatalf("Invalid BREACHED_PASSWORD_THRESHOLD. Error: %s", err.Error())
+			}
+		}
+
+		corpus, err := pwned.Open(path, threshold)
+		if err != nil {
+			log.Fatalf("Failed to open breached password corpus. Error: %s", err.Error())
+		}
+		defer corpus.Close()
+		breached = corpus
+	}
+
+	passwordPolicy, err := newPasswordPolicy()
+	if err != nil {
+		log.Fatalf("Failed to configure password policy. Error: %s", err.Error())
+	}
+
+	lockout, err := newLockoutConfig()
+	if err != nil {
+		log.Fatalf("Failed to configure account lockout. Error: %s", err.Error())
+	}
+
+	verificationPolicy := os.Getenv("EMAIL_VERIFICATION_POLICY")
+	switch verificationPolicy {
+	case "":
+		verificationPolicy = domain.EmailVerificationNone
+	case domain.EmailVerificationNone, domain.EmailVerificationBlockLogin, domain.EmailVerificationRestrict:
+	default:
+		log.Fatalf("Unknown email verification policy %q", verificationPolicy)
+	}
+
+	verificationURL := os.Getenv("EMAIL_VERIFICATION_URL")
+	if verificationURL == "" {
+		verificationURL = "http://localhost:5173/verify-email"
+	}
+
+	verificationUseCase := usecase.NewEmailVerificationUsecase(verificationRepo, repo, mailQueue, verificationPolicy, verificationURL, hideAccounts)
+	useCase := usecase.NewUserUsecase(repo, refreshTokenRepo, sessionRepo, mfaRepo, webauthnRepo, blackList, mailQueue, hasher, breached, passwordPolicy, verificationUseCase, passwordReset, lockout, hideAccounts, accessToken, refreshToken)
+	oauthRepo, err := repository.NewOAuthRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create oauth repository")
+	}
+
+	// ID tokens are verified by relying parties against the JWKS, so OpenID
+	// Connect is only on when the access keys are asymmetric ones from
+	// JWT_KEYS_DIR. HMAC secrets are never shared with a relying party.
+	oidcEnabled := os.Getenv("JWT_KEYS_DIR") != ""
+	idToken := jwt.TokenConfig{
+		Issuer: issuer,
+		Expiry: time.Hour,
+	}
+	if oidcEnabled {
+		idToken.Keys = accessKeys
+	} else {
+		log.Println("JWT_KEYS_DIR is not set, OpenID Connect is disabled")
+	}
+
+	oauthUseCase := usecase.NewOAuthUsecase(oauthRepo, useCase, accessToken, idToken)
+
+	totpIssuer := os.Getenv("TOTP_ISSUER")
+	if totpIssuer == "" {
+		totpIssuer = "Go Register Login"
+	}
+	relyingParty, err := newWebAuthn()
+	if err != nil {
+		log.Fatalf("Failed to configure webauthn. Error: %s", err.Error())
+	}
+
+	webauthnUseCase := usecase.NewWebAuthnUsecase(webauthnRepo, useCase, relyingParty)
+	magicLinkURL := os.Getenv("MAGIC_LINK_URL")
+	if magicLinkURL == "" {
+		magicLinkURL = "http://localhost:5173/magic-link"
+	}
+	magicLinkSecret, err := secretFromEnv("MAGIC_LINK_SECRET")
+	if err != nil {
+		log.Fatal(err)
+	}
+	magicLinkUseCase := usecase.NewMagicLinkUsecase(magicLinkRepo, repo, useCase, mailQueue, magicLinkSecret, magicLinkURL, hideAccounts)
+	emailChangeURL := os.Getenv("EMAIL_CHANGE_URL")
+	if emailChangeURL == "" {
+		emailChangeURL = "http://localhost:5173/confirm-email-change"
+	}
+	emailChangeCancelURL := os.Getenv("EMAIL_CHANGE_CANCEL_URL")
+	if emailChangeCancelURL == "" {
+		emailChangeCancelURL = "http://localhost:5173/cancel-email-change"
+	}
+	emailChangeUseCase := usecase.NewEmailChangeUsecase(emailChangeRepo, repo, useCase, mailQueue, hasher, emailChangeURL, emailChangeCancelURL, hideAccounts)
+	mfaUseCase := usecase.NewMFAUsecase(mfaRepo, useCase, webauthnUseCase, mfaKey, totpIssuer)
+
 	h := http.NewUserHandler(useCase, blackList)
+	oauthHandler := http.NewOAuthHandler(oauthUseCase)
+	mfaHandler := http.NewMFAHandler(mfaUseCase)
+	webauthnHandler := http.NewWebAuthnHandler(webauthnUseCase)
+	magicLinkHandler := http.NewMagicLinkHandler(magicLinkUseCase)
+	verificationHandler := http.NewEmailVerificationHandler(verificationUseCase)
+	emailChangeHandler := http.NewEmailChangeHandler(emailChangeUseCase)
+
+	requireVerified := func(ctx *gin.Context) { ctx.Next() }
+	if verificationPolicy == domain.EmailVerificationRestrict {
+		requireVerified = middleware.RequireVerifiedEmail(repo)
+	}
+	oidcHandler := http.NewOIDCHandler(oauthUseCase, accessKeys, issuer, os.Getenv("OAUTH_AUTHORIZE_URL"))
+	jwksHandler := http.NewJWKSHandler(accessKeys)
 
 	rateLimiter := middleware.NewIPRateLimiter(1, 5)
 
@@ -48,6 +260,19 @@ func main() {
 		AllowCredentials: true,
 	}))
 	
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
+	if oidcEnabled {
+		r.GET("/.well-known/openid-configuration", oidcHandler.Discovery)
+
+		userInfo := r.Group("/userinfo")
+		userInfo.Use(middleware.AuthMiddleware(accessToken, blackList, sessionRepo, repo), middleware.RequireScope(domain.ScopeOpenID))
+		{
+			userInfo.GET("", oidcHandler.UserInfo)
+			userInfo.POST("", oidcHandler.UserInfo)
+		}
+	}
+
 	api := r.Group("/api")
 	api.Use(middleware.RateLimiterMiddleware(rateLimiter))
 	{
@@ -56,16 +281,410 @@ func main() {
 		api.POST("/auth/refresh", h.Refresh)
 		api.POST("/auth/forgot-password", h.ForgotPassword)
 		api.POST("/auth/reset-password", h.ResetPassword)
+		api.POST("/auth/unlock", h.UnlockAccount)
+		api.POST("/auth/verify-email", verificationHandler.Confirm)
+		api.POST("/auth/verify-email/resend", verificationHandler.Resend)
+		api.POST("/auth/email/confirm", emailChangeHandler.Confirm)
+		api.POST("/auth/email/cancel", emailChangeHandler.Cancel)
+		api.POST("/auth/magic-link", magicLinkHandler.Send)
+		api.POST("/auth/magic-link/verify", magicLinkHandler.Login)
+		api.POST("/auth/mfa/verify", mfaHandler.Verify)
+		api.POST("/auth/mfa/webauthn/begin", mfaHandler.BeginWebAuthn)
+		api.POST("/auth/webauthn/login/begin", webauthnHandler.BeginLogin)
+		api.POST("/auth/webauthn/login/finish", webauthnHandler.FinishLogin)
 
 		auth := api.Group("/auth")
-		auth.Use(middleware.AuthMiddleware(os.Getenv("JWT_ACCESS_SECRET"), blackList))
+		auth.Use(middleware.AuthMiddleware(accessToken, blackList, sessionRepo, repo))
 		{
-			auth.GET("/profile", h.GetProfile)
-			auth.PUT("/profile", h.UpdateProfile)
+			auth.GET("/profile", middleware.RequireScope(domain.ScopeProfile), h.GetProfile)
 			auth.POST("/logout", h.Logout)
+
+			// Unverified users under the restrict policy may still fix a
+			// mistyped address, which is the only way to get verified.
+			auth.POST("/email", middleware.RequireFirstParty(), emailChangeHandler.Request)
+
+			account := auth.Group("")
+			account.Use(middleware.RequireFirstParty(), requireVerified)
+			{
+				account.PUT("/profile", h.UpdateProfile)
+				account.POST("/password", h.ChangePassword)
+				account.POST("/logout-all", h.LogoutAll)
+				account.GET("/sessions", h.ListSessions)
+				account.DELETE("/sessions/:id", h.RevokeSession)
+				account.POST("/mfa/totp/enroll", mfaHandler.EnrollTOTP)
+				account.POST("/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+				account.DELETE("/mfa/totp", mfaHandler.DisableTOTP)
+				account.POST("/mfa/recovery-codes/regenerate", mfaHandler.RegenerateRecoveryCodes)
+				account.POST("/webauthn/register/begin", webauthnHandler.BeginRegistration)
+				account.POST("/webauthn/register/finish", webauthnHandler.FinishRegistration)
+				account.GET("/webauthn/credentials", webauthnHandler.ListCredentials)
+				account.DELETE("/webauthn/credentials/:id", webauthnHandler.DeleteCredential)
+			}
+		}
+	}
+
+	service := api.Group("/service")
+	service.Use(middleware.AuthMiddleware(accessToken, blackList, sessionRepo, repo), middleware.RequireServiceAccount())
+	{
+		service.GET("/users/:id", middleware.RequireScope(domain.ScopeUsersRead), h.GetUserById)
+		service.POST("/users/:id/unlock", middleware.RequireScope(domain.ScopeUsersUnlock), h.UnlockUser)
+	}
+
+	oauth := r.Group("/oauth")
+	oauth.Use(middleware.RateLimiterMiddleware(rateLimiter))
+	{
+		oauth.POST("/token", oauthHandler.Token)
+		oauth.POST("/introspect", oauthHandler.Introspect)
+		oauth.POST("/revoke", oauthHandler.Revoke)
+
+		consent := oauth.Group("")
+		consent.Use(middleware.AuthMiddleware(accessToken, blackList, sessionRepo, repo), middleware.RequireFirstParty(), requireVerified)
+		{
+			consent.GET("/authorize", oauthHandler.AuthorizeInfo)
+			consent.POST("/authorize", oauthHandler.Authorize)
+			consent.POST("/clients", oauthHandler.RegisterClient)
 		}
 	}
 
 	fmt.Println("Server started at port :8080")
 	r.Run(":8080")
-}
\ No newline at end of file
+}
+
+func newBlacklist(db *sql.DB) (jwt.Blacklist, error) {
+	switch os.Getenv("TOKEN_BLACKLIST_DRIVER") {
+	case "mysql":
+		return jwt.NewMySQLBlacklist(db, 10*time.Minute), nil
+	case "redis":
+		client, err := database.ConnectRedis()
+		if err != nil {
+			return nil, err
+		}
+		return jwt.NewRedisBlacklist(client), nil
+	case "", "memory":
+		return jwt.NewTokenBlacklist(time.Minute), nil
+	default:
+		return nil, fmt.Errorf("unknown token blacklist driver %q", os.Getenv("TOKEN_BLACKLIST_DRIVER"))
+	}
+}
+
+func newMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Go Register Login <no-reply@localhost>"
+	}
+
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		return mailer.NewSMTPMailer(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from), nil
+	case "", "maildir":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return mailer.NewMaildirMailer(dir, from)
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", os.Getenv("MAIL_DRIVER"))
+	}
+}
+
+func newPasswordResetConfig() (usecase.PasswordResetConfig, error) {
+	cfg := usecase.PasswordResetConfig{
+		Method:  os.Getenv("PASSWORD_RESET_METHOD"),
+		LinkURL: os.Getenv("PASSWORD_RESET_URL"),
+		LinkTTL: 30 * time.Minute,
+	}
+
+	switch cfg.Method {
+	case "":
+		cfg.Method = domain.ResetMethodOTP
+	case domain.ResetMethodOTP, domain.ResetMethodLink:
+	default:
+		return cfg, fmt.Errorf("unknown password reset method %q", cfg.Method)
+	}
+
+	if cfg.LinkURL == "" {
+		cfg.LinkURL = "http://localhost:5173/reset-password"
+	}
+
+	secret, err := secretFromEnv("PASSWORD_RESET_SECRET")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Secret = secret
+
+	if ttl := os.Getenv("PASSWORD_RESET_LINK_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.LinkTTL = d
+	}
+
+	return cfg, nil
+}
+
+func newPasswordHasher() (utils.PasswordHasher, error) {
+	cfg := utils.DefaultPasswordHashConfig()
+
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		cfg.Algorithm = algorithm
+	}
+
+	if env := os.Getenv("ARGON2_MEMORY"); env != "" {
+		n, err := strconv.ParseUint(env, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARGON2_MEMORY: %w", err)
+		}
+		cfg.Argon2.Memory = uint32(n)
+	}
+
+	if env := os.Getenv("ARGON2_ITERATIONS"); env != "" {
+		n, err := strconv.ParseUint(env, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARGON2_ITERATIONS: %w", err)
+		}
+		cfg.Argon2.Iterations = uint32(n)
+	}
+
+	if env := os.Getenv("ARGON2_PARALLELISM"); env != "" {
+		n, err := strconv.ParseUint(env, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ARGON2_PARALLELISM: %w", err)
+		}
+		cfg.Argon2.Parallelism = uint8(n)
+	}
+
+	if env := os.Getenv("BCRYPT_COST"); env != "" {
+		n, err := strconv.Atoi(env)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BCRYPT_COST: %w", err)
+		}
+		cfg.BcryptCost = n
+	}
+
+	hasher, err := utils.NewPasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	peppers, version, err := newPeppers()
+	if err != nil || len(peppers) == 0 {
+		return hasher, err
+	}
+
+	return utils.NewPepperedHasher(hasher, version, peppers)
+}
+
+// placeholderPepper is the example value from env.example, which must never
+// reach production.
+const placeholderPepper = "change-me-to-a-long-random-secret"
+
+// newPeppers reads PASSWORD_PEPPERS as a list of version:secret pairs. The
+// current version is PASSWORD_PEPPER_VERSION, or the highest one listed.
+// Errors name the entry by position or version, never by its secret.
+func newPeppers() (map[int][]byte, int, error) {
+	peppers := map[int][]byte{}
+	current := 0
+
+	for i, entry := range envList("PASSWORD_PEPPERS") {
+		v, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, 0, fmt.Errorf("PASSWORD_PEPPERS entry %d must look like version:secret", i+1)
+		}
+
+		version, err := strconv.Atoi(v)
+		if err != nil || version <= 0 {
+			return nil, 0, fmt.Errorf("PASSWORD_PEPPERS entry %d: version %q must be a positive number", i+1, v)
+		}
+		if _, found := peppers[version]; found {
+			return nil, 0, fmt.Errorf("PASSWORD_PEPPERS lists version %d twice", version)
+		}
+		if secret == placeholderPepper {
+			return nil, 0, fmt.Errorf("PASSWORD_PEPPERS version %d still has the placeholder secret from env.example", version)
+		}
+		if len(secret) < 32 {
+			return nil, 0, fmt.Errorf("PASSWORD_PEPPERS version %d: secret must be at least 32 characters", version)
+		}
+
+		peppers[version] = []byte(secret)
+		current = max(current, version)
+	}
+
+	if env := os.Getenv("PASSWORD_PEPPER_VERSION"); env != "" {
+		version, err := strconv.Atoi(env)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid PASSWORD_PEPPER_VERSION: %w", err)
+		}
+		current = version
+	}
+
+	return peppers, current, nil
+}
+
+func newPasswordPolicy() (utils.PasswordPolicy, error) {
+	policy := utils.DefaultPasswordPolicy()
+
+	ints := map[string]*int{
+		"PASSWORD_MIN_LENGTH":   &policy.MinLength,
+		"PASSWORD_MAX_LENGTH":   &policy.MaxLength,
+		"PASSWORD_MIN_STRENGTH": &policy.MinStrength,
+	}
+	for key, value := range ints {
+		if env := os.Getenv(key); env != "" {
+			n, err := strconv.Atoi(env)
+			if err != nil {
+				return policy, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*value = n
+		}
+	}
+
+	bools := map[string]*bool{
+		"PASSWORD_REQUIRE_UPPER":  &policy.RequireUpper,
+		"PASSWORD_REQUIRE_LOWER":  &policy.RequireLower,
+		"PASSWORD_REQUIRE_DIGIT":  &policy.RequireDigit,
+		"PASSWORD_REQUIRE_SYMBOL": &policy.RequireSymbol,
+	}
+	for key, value := range bools {
+		if env := os.Getenv(key); env != "" {
+			b, err := strconv.ParseBool(env)
+			if err != nil {
+				return policy, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*value = b
+		}
+	}
+
+	policy.BannedWords = envList("PASSWORD_BANNED_WORDS")
+
+	if policy.MinLength < 1 || policy.MaxLength < 1 {
+		return policy, errors.New("PASSWORD_MIN_LENGTH and PASSWORD_MAX_LENGTH must be positive")
+	}
+	if policy.MaxLength < policy.MinLength {
+		return policy, errors.New("PASSWORD_MAX_LENGTH must not be less than PASSWORD_MIN_LENGTH")
+	}
+
+	if policy.MinStrength < 0 || policy.MinStrength > 4 {
+		return policy, errors.New("PASSWORD_MIN_STRENGTH must be between 0 and 4")
+	}
+
+	return policy, nil
+}
+
+func newLockoutConfig() (usecase.LockoutConfig, error) {
+	cfg := usecase.LockoutConfig{
+		MaxFailures: 10,
+		Duration:    15 * time.Minute,
+		UnlockURL:   os.Getenv("ACCOUNT_UNLOCK_URL"),
+	}
+
+	if threshold := os.Getenv("ACCOUNT_LOCKOUT_THRESHOLD"); threshold != "" {
+		n, err := strconv.Atoi(threshold)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid ACCOUNT_LOCKOUT_THRESHOLD: %w", err)
+		}
+		cfg.MaxFailures = n
+	}
+
+	if duration := os.Getenv("ACCOUNT_LOCKOUT_DURATION"); duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid ACCOUNT_LOCKOUT_DURATION: %w", err)
+		}
+		cfg.Duration = d
+	}
+
+	if cfg.MaxFailures < 1 {
+		return cfg, errors.New("ACCOUNT_LOCKOUT_THRESHOLD must be positive")
+	}
+	if cfg.Duration <= 0 {
+		return cfg, errors.New("ACCOUNT_LOCKOUT_DURATION must be positive")
+	}
+
+	// Turning the lock off takes an explicit setting; the per-address
+	// backoff still applies.
+	if enabled := os.Getenv("ACCOUNT_LOCKOUT_ENABLED"); enabled != "" {
+		b, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid ACCOUNT_LOCKOUT_ENABLED: %w", err)
+		}
+		if !b {
+			cfg.MaxFailures = 0
+		}
+	}
+
+	if cfg.UnlockURL == "" {
+		cfg.UnlockURL = "http://localhost:5173/unlock-account"
+	}
+
+	return cfg, nil
+}
+
+func newWebAuthn() (*webauthn.WebAuthn, error) {
+	rpId := os.Getenv("WEBAUTHN_RP_ID")
+	if rpId == "" {
+		rpId = "localhost"
+	}
+
+	rpName := os.Getenv("WEBAUTHN_RP_NAME")
+	if rpName == "" {
+		rpName = "Go Register Login"
+	}
+
+	origins := envList("WEBAUTHN_RP_ORIGINS")
+	if len(origins) == 0 {
+		origins = []string{"http://localhost:3000", "http://localhost:5173"}
+	}
+
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpId,
+		RPDisplayName: rpName,
+		RPOrigins:     origins,
+	})
+}
+
+func newAccessKeySet() (*jwt.KeySet, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		return jwt.NewHMACKeySet(os.Getenv("JWT_ACCESS_SECRET"), envList("JWT_ACCESS_PREVIOUS_SECRETS")...), nil
+	}
+
+	keys, err := jwt.LoadKeySet(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := keys.Reload(dir); err != nil {
+				log.Printf("Failed to reload signing keys. Error: %s", err.Error())
+			}
+		}
+	}()
+
+	return keys, nil
+}
+
+// secretFromEnv reads an HMAC key. Short keys are refused; an empty one
+// would let anyone compute the MACs.
+func secretFromEnv(key string) ([]byte, error) {
+	secret := os.Getenv(key)
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("%s must be at least 32 characters", key)
+	}
+
+	return []byte(secret), nil
+}
+
+func envList(key string) []string {
+	values := []string{}
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
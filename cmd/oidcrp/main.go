@@ -0,0 +1,229 @@
+// Command oidcrp is a minimal OpenID Connect relying party used to exercise
+// the provider locally. It runs the authorization code + PKCE flow, verifies
+// the ID token against the published JWKS and calls the userinfo endpoint.
+// The provider only serves OpenID Connect when it signs with keys from
+// JWT_KEYS_DIR.
+//
+// Because the consent step needs a logged-in user, pass -access-token (from
+// /api/user/login) to approve the request automatically; otherwise open the
+// printed URL in a browser that is logged in to the frontend.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	IDToken          string `json:"id_token"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func main() {
+	issuer := flag.String("issuer", "http://localhost:8080", "provider issuer URL")
+	clientId := flag.String("client-id", "", "registered client id")
+	clientSecret := flag.String("client-secret", "", "client secret, empty for public clients")
+	listen := flag.String("listen", "127.0.0.1:9090", "address for the redirect URI callback")
+	scope := flag.String("scope", "openid profile email", "requested scopes")
+	accessToken := flag.String("access-token", "", "first-party access token used to approve consent automatically")
+	flag.Parse()
+
+	if *clientId == "" {
+		log.Fatal("-client-id is required")
+	}
+
+	var disc discovery
+	if err := getJSON(strings.TrimSuffix(*issuer, "/")+"/.well-known/openid-configuration", "", &disc); err != nil {
+		log.Fatalf("discovery failed: %s", err)
+	}
+
+	var set jwt.JWKS
+	if err := getJSON(disc.JwksURI, "", &set); err != nil {
+		log.Fatalf("fetching JWKS failed: %s", err)
+	}
+
+	keys, err := jwt.NewKeySetFromJWKS(set)
+	if err != nil {
+		log.Fatalf("invalid JWKS: %s", err)
+	}
+
+	verifier, _ := utils.RandomToken(32)
+	state, _ := utils.RandomToken(16)
+	nonce, _ := utils.RandomToken(16)
+	sum := sha256.Sum256([]byte(verifier))
+	redirectURI := "http://" + *listen + "/callback"
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {*clientId},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {*scope},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	codes := make(chan url.Values, 1)
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "You can close this window.")
+		codes <- r.URL.Query()
+	})}
+	go server.Serve(ln)
+	defer server.Shutdown(context.Background())
+
+	if *accessToken != "" {
+		if err := approve(*issuer, *accessToken, params); err != nil {
+			log.Fatalf("consent failed: %s", err)
+		}
+	} else {
+		fmt.Println("Open this URL to sign in:")
+		fmt.Println(disc.AuthorizationEndpoint + "?" + params.Encode())
+	}
+
+	var callback url.Values
+	select {
+	case callback = <-codes:
+	case <-time.After(5 * time.Minute):
+		log.Fatal("timed out waiting for the callback")
+	}
+
+	if callback.Get("error") != "" {
+		log.Fatalf("authorization failed: %s: %s", callback.Get("error"), callback.Get("error_description"))
+	}
+	if callback.Get("state") != state {
+		log.Fatal("state mismatch")
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {callback.Get("code")},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+		"client_id":     {*clientId},
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if *clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(*clientId), url.QueryEscape(*clientSecret))
+	}
+
+	var tokens tokenResponse
+	if err := doJSON(req, &tokens); err != nil {
+		log.Fatalf("token request failed: %s", err)
+	}
+	if tokens.Error != "" {
+		log.Fatalf("token request failed: %s: %s", tokens.Error, tokens.ErrorDescription)
+	}
+
+	claims, err := jwt.ValidateIDToken(tokens.IDToken, *clientId, nonce, jwt.TokenConfig{Keys: keys, Issuer: disc.Issuer})
+	if err != nil {
+		log.Fatalf("id_token rejected: %s", err)
+	}
+	printJSON("id_token claims", claims)
+
+	var info map[string]any
+	if err := getJSON(disc.UserinfoEndpoint, tokens.AccessToken, &info); err != nil {
+		log.Fatalf("userinfo failed: %s", err)
+	}
+	printJSON("userinfo", info)
+
+	if info["sub"] != claims.Subject {
+		log.Fatal("userinfo sub does not match id_token sub")
+	}
+
+	fmt.Println("OK")
+}
+
+func approve(issuer, accessToken string, params url.Values) error {
+	form := url.Values{}
+	for key, values := range params {
+		form[key] = values
+	}
+	form.Set("approve", "true")
+
+	req, _ := http.NewRequest(http.MethodPost, strings.TrimSuffix(issuer, "/")+"/oauth/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	var res struct {
+		Data struct {
+			RedirectTo string `json:"redirect_to"`
+		} `json:"data"`
+	}
+	if err := doJSON(req, &res); err != nil {
+		return err
+	}
+	if res.Data.RedirectTo == "" {
+		return errors.New("no redirect returned")
+	}
+
+	resp, err := http.Get(res.Data.RedirectTo)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func getJSON(endpoint, bearer string, out any) error {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s returned %s", req.URL, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func printJSON(label string, v any) {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Printf("%s:\n%s\n", label, data)
+}
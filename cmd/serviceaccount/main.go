@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	repository "github.com/Hdeee1/go-register-login-profile/internal/repository/mysql"
+	"github.com/Hdeee1/go-register-login-profile/internal/usecase"
+	"github.com/Hdeee1/go-register-login-profile/pkg/database"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	name := flag.String("name", "", "service account name")
+	scopes := flag.String("scopes", strings.Join(domain.ServiceScopes, " "), "space separated scopes")
+	flag.Parse()
+
+	if *name == "" {
+		log.Fatal("-name is required")
+	}
+
+	if err := godotenv.Load(".env"); err != nil {
+		log.Fatal("Failed to load env")
+	}
+
+	db, err := database.ConnectMySQL()
+	if err != nil {
+		log.Fatalf("Failed to connect database. Error: %s", err.Error())
+	}
+
+	oauthRepo, err := repository.NewOAuthRepository(db)
+	if err != nil {
+		log.Fatal("Failed to create oauth repository")
+	}
+
+	// Only client management is used here, so no token issuing dependencies
+	// are wired up.
+	oauthUseCase := usecase.NewOAuthUsecase(oauthRepo, nil, jwt.TokenConfig{}, jwt.TokenConfig{})
+
+	input := domain.CreateServiceAccountRequest{
+		Name:   *name,
+		Scopes: strings.Fields(*scopes),
+	}
+
+	client, secret, err := oauthUseCase.CreateServiceAccount(input, context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("client_id:     %s\n", client.Id)
+	fmt.Printf("client_secret: %s\n", secret)
+	fmt.Printf("scopes:        %s\n", strings.Join(client.Scopes, " "))
+	fmt.Println("Store the secret now, it cannot be shown again.")
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type EmailChangeHandler struct {
+	emailChangeUseCase domain.EmailChangeUsecase
+}
+
+func NewEmailChangeHandler(e domain.EmailChangeUsecase) *EmailChangeHandler {
+	return &EmailChangeHandler{emailChangeUseCase: e}
+}
+
+func (h *EmailChangeHandler) Request(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.ChangeEmailRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	input.IPAddress = ctx.ClientIP()
+
+	err := h.emailChangeUseCase.Request(value.(int), input, ctx)
+	if writeThrottleError(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("A confirmation link has been sent to your new email address", nil))
+}
+
+func (h *EmailChangeHandler) Confirm(ctx *gin.Context) {
+	var input domain.EmailChangeTokenRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.emailChangeUseCase.Confirm(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("Your email address has been changed", nil))
+}
+
+func (h *EmailChangeHandler) Cancel(ctx *gin.Context) {
+	var input domain.EmailChangeTokenRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.emailChangeUseCase.Cancel(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The email change has been cancelled. If it was already confirmed, check your inbox to choose a new password", nil))
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationHandler struct {
+	verificationUseCase domain.EmailVerificationUsecase
+}
+
+func NewEmailVerificationHandler(v domain.EmailVerificationUsecase) *EmailVerificationHandler {
+	return &EmailVerificationHandler{verificationUseCase: v}
+}
+
+func (h *EmailVerificationHandler) Confirm(ctx *gin.Context) {
+	var input domain.VerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.verificationUseCase.Confirm(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("Your email address has been verified", nil))
+}
+
+func (h *EmailVerificationHandler) Resend(ctx *gin.Context) {
+	var input domain.ResendVerificationRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.verificationUseCase.Resend(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The verification email has been sent", nil))
+}
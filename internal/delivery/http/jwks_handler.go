@@ -0,0 +1,21 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSHandler struct {
+	keys *jwt.KeySet
+}
+
+func NewJWKSHandler(keys *jwt.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+func (h *JWKSHandler) GetJWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.keys.JWKS())
+}
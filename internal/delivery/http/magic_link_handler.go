@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkHandler struct {
+	magicLinkUseCase domain.MagicLinkUsecase
+}
+
+func NewMagicLinkHandler(m domain.MagicLinkUsecase) *MagicLinkHandler {
+	return &MagicLinkHandler{magicLinkUseCase: m}
+}
+
+func (h *MagicLinkHandler) Send(ctx *gin.Context) {
+	var input domain.MagicLinkRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.magicLinkUseCase.Send(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The login link has been sent to your email", nil))
+}
+
+func (h *MagicLinkHandler) Login(ctx *gin.Context) {
+	var input domain.MagicLinkLoginRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	input.UserAgent = ctx.Request.UserAgent()
+	input.IPAddress = ctx.ClientIP()
+
+	result, err := h.magicLinkUseCase.Login(input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", err.Error()))
+		return
+	}
+
+	writeLoginResult(ctx, result)
+}
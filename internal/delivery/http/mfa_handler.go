@@ -0,0 +1,141 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaUseCase domain.MFAUsecase
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func NewMFAHandler(m domain.MFAUsecase) *MFAHandler {
+	return &MFAHandler{mfaUseCase: m}
+}
+
+func (h *MFAHandler) EnrollTOTP(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	res, err := h.mfaUseCase.EnrollTOTP(value.(int), ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *MFAHandler) ConfirmTOTP(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	codes, err := h.mfaUseCase.ConfirmTOTP(value.(int), input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", recoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+func (h *MFAHandler) DisableTOTP(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.mfaUseCase.DisableTOTP(value.(int), input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", "two-factor authentication disabled"))
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.TOTPCodeRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	codes, err := h.mfaUseCase.RegenerateRecoveryCodes(value.(int), input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", recoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+func (h *MFAHandler) BeginWebAuthn(ctx *gin.Context) {
+	var input domain.MFAWebAuthnRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	res, err := h.mfaUseCase.BeginWebAuthn(input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *MFAHandler) Verify(ctx *gin.Context) {
+	var input domain.MFAVerifyRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	input.UserAgent = ctx.Request.UserAgent()
+	input.IPAddress = ctx.ClientIP()
+
+	result, err := h.mfaUseCase.Verify(input, ctx)
+	if writeThrottleError(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", err.Error()))
+		return
+	}
+
+	writeLoginResult(ctx, result)
+}
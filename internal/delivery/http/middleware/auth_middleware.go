@@ -4,11 +4,12 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
 	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
 	"github.com/gin-gonic/gin"
 )
 
-func AuthMiddleware(secretKey string, blacklist *jwt.TokenBlacklist) gin.HandlerFunc {
+func AuthMiddleware(cfg jwt.TokenConfig, blacklist jwt.Blacklist, sessions domain.SessionRepository, users domain.UserRepository) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authHeader := ctx.GetHeader("Authorization")
 		if authHeader == "" {
@@ -24,19 +25,45 @@ func AuthMiddleware(secretKey string, blacklist *jwt.TokenBlacklist) gin.Handler
 
 		tokenString := parts[1]
 
-		isBlacklist := blacklist.IsBlacklisted(tokenString)
-		if isBlacklist == true {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, "Token has been invalidated")
+		claims, err := jwt.ValidateToken(tokenString, cfg)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return 
 		}
 
-		claims, err := jwt.ValidateToken(tokenString, secretKey)
-		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		isBlacklist, err := blacklist.IsBlacklisted(claims.ID, ctx)
+		if err != nil || isBlacklist {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been invalidated"})
 			return 
 		}
 
+		if claims.IsServiceAccount() {
+			ctx.Set("principal_type", "service_account")
+			ctx.Set("client_id", claims.ClientId)
+			ctx.Set("scope", claims.Scope)
+			ctx.Set("claims", claims)
+			ctx.Next()
+			return
+		}
+
+		session, err := sessions.GetById(claims.SessionId, ctx)
+		if err != nil || session.UserId != claims.UserId || session.RevokedAt != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			return 
+		}
+
+		version, err := users.GetTokenVersion(claims.UserId, ctx)
+		if err != nil || version != claims.TokenVersion {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token has been invalidated"})
+			return
+		}
+
+		ctx.Set("principal_type", "user")
 		ctx.Set("user_id", claims.UserId)
+		ctx.Set("session_id", claims.SessionId)
+		ctx.Set("client_id", claims.ClientId)
+		ctx.Set("scope", claims.Scope)
+		ctx.Set("claims", claims)
 		ctx.Next()
 	}
 }
\ No newline at end of file
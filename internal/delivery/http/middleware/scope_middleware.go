@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireFirstParty rejects tokens that were issued to an OAuth client, so
+// account management stays with our own frontends.
+func RequireFirstParty() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString("client_id") != "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to third-party clients"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// RequireScope lets first-party tokens through and requires OAuth tokens to
+// carry scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString("client_id") != "" && !slices.Contains(strings.Fields(ctx.GetString("scope")), scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func RequireServiceAccount() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString("principal_type") != "service_account" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint is only available to service accounts"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
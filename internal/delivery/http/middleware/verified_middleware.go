@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail blocks users whose email address has not been
+// verified. It is only installed under the restrict verification policy.
+func RequireVerifiedEmail(users domain.UserRepository) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		value, exist := ctx.Get("user_id")
+		if !exist {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		user, err := users.GetById(value.(int))
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		if user.EmailVerifiedAt == nil {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Please verify your email address first"})
+			return
+		}
+
+		ctx.Next()
+	}
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type OAuthHandler struct {
+	oauthUseCase domain.OAuthUsecase
+}
+
+type registerClientResponse struct {
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"client_name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+type consentResponse struct {
+	ClientId    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+	State       string   `json:"state,omitempty"`
+}
+
+func NewOAuthHandler(o domain.OAuthUsecase) *OAuthHandler {
+	return &OAuthHandler{oauthUseCase: o}
+}
+
+func (h *OAuthHandler) RegisterClient(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.RegisterClientRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	client, secret, err := h.oauthUseCase.RegisterClient(value.(int), input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	res := registerClientResponse{
+		ClientId: client.Id,
+		ClientSecret: secret,
+		Name: client.Name,
+		RedirectURIs: client.RedirectURIs,
+		Scopes: client.Scopes,
+		Public: client.Public,
+	}
+
+	ctx.JSON(http.StatusCreated, response.BuildSuccessResponse("CREATED", res))
+}
+
+// AuthorizeInfo describes the pending request so the frontend can render a
+// consent screen before the user approves or denies it.
+func (h *OAuthHandler) AuthorizeInfo(ctx *gin.Context) {
+	var input domain.AuthorizeRequest
+	if err := ctx.ShouldBindQuery(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	client, redirectURI, scopes, err := h.oauthUseCase.ValidateAuthorizeRequest(input, ctx)
+	if err != nil {
+		var oauthErr *domain.OAuthError
+		if errors.As(err, &oauthErr) {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	res := consentResponse{
+		ClientId: client.Id,
+		ClientName: client.Name,
+		RedirectURI: redirectURI,
+		Scopes: scopes,
+		State: input.State,
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *OAuthHandler) Authorize(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.AuthorizeRequest
+	if err := ctx.ShouldBind(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	redirectTo, err := h.oauthUseCase.Authorize(value.(int), input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"redirect_to": redirectTo}))
+}
+
+func (h *OAuthHandler) Token(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	var input domain.TokenRequest
+	if err := ctx.ShouldBind(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": validator.ParseValidatorError(err)})
+		return
+	}
+
+	usedBasicAuth := h.readClientCredentials(ctx, &input.ClientId, &input.ClientSecret)
+	input.UserAgent = ctx.Request.UserAgent()
+	input.IPAddress = ctx.ClientIP()
+
+	res, err := h.oauthUseCase.Token(input, ctx)
+	if err != nil {
+		h.writeOAuthError(ctx, err, usedBasicAuth)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h *OAuthHandler) Introspect(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	var input domain.IntrospectRequest
+	if err := ctx.ShouldBind(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": validator.ParseValidatorError(err)})
+		return
+	}
+
+	usedBasicAuth := h.readClientCredentials(ctx, &input.ClientId, &input.ClientSecret)
+
+	res, err := h.oauthUseCase.Introspect(input, ctx)
+	if err != nil {
+		h.writeOAuthError(ctx, err, usedBasicAuth)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h *OAuthHandler) Revoke(ctx *gin.Context) {
+	var input domain.RevokeRequest
+	if err := ctx.ShouldBind(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": validator.ParseValidatorError(err)})
+		return
+	}
+
+	usedBasicAuth := h.readClientCredentials(ctx, &input.ClientId, &input.ClientSecret)
+
+	if err := h.oauthUseCase.Revoke(input, ctx); err != nil {
+		h.writeOAuthError(ctx, err, usedBasicAuth)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// readClientCredentials prefers HTTP Basic client authentication and falls
+// back to client_id/client_secret in the form body.
+func (h *OAuthHandler) readClientCredentials(ctx *gin.Context, clientId, clientSecret *string) bool {
+	user, pass, ok := ctx.Request.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	if id, err := url.QueryUnescape(user); err == nil {
+		*clientId = id
+	}
+	if secret, err := url.QueryUnescape(pass); err == nil {
+		*clientSecret = secret
+	}
+
+	return true
+}
+
+func (h *OAuthHandler) writeOAuthError(ctx *gin.Context, err error, usedBasicAuth bool) {
+	var oauthErr *domain.OAuthError
+	if !errors.As(err, &oauthErr) {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		if usedBasicAuth {
+			ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	}
+
+	ctx.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+}
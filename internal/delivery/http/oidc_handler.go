@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCHandler struct {
+	oauthUseCase      domain.OAuthUsecase
+	keys              *jwt.KeySet
+	issuer            string
+	authorizeEndpoint string
+}
+
+type discoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// NewOIDCHandler serves discovery and userinfo. authorizeEndpoint is the
+// browser-facing consent page, which lives in the frontend rather than in
+// this API because it needs a logged-in user.
+func NewOIDCHandler(o domain.OAuthUsecase, keys *jwt.KeySet, issuer, authorizeEndpoint string) *OIDCHandler {
+	issuer = strings.TrimSuffix(issuer, "/")
+	if authorizeEndpoint == "" {
+		authorizeEndpoint = issuer + "/oauth/authorize"
+	}
+
+	return &OIDCHandler{
+		oauthUseCase:      o,
+		keys:              keys,
+		issuer:            issuer,
+		authorizeEndpoint: authorizeEndpoint,
+	}
+}
+
+func (h *OIDCHandler) Discovery(ctx *gin.Context) {
+	res := discoveryResponse{
+		Issuer:                            h.issuer,
+		AuthorizationEndpoint:             h.authorizeEndpoint,
+		TokenEndpoint:                     h.issuer + "/oauth/token",
+		IntrospectionEndpoint:             h.issuer + "/oauth/introspect",
+		RevocationEndpoint:                h.issuer + "/oauth/revoke",
+		UserinfoEndpoint:                  h.issuer + "/userinfo",
+		JwksURI:                           h.issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   domain.SupportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  h.keys.Algorithms(),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "name", "preferred_username", "email", "email_verified"},
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, res)
+}
+
+func (h *OIDCHandler) UserInfo(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	scope := ctx.GetString("scope")
+	if ctx.GetString("client_id") == "" {
+		scope = strings.Join(domain.SupportedScopes, " ")
+	}
+
+	info, err := h.oauthUseCase.UserInfo(value.(int), scope, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
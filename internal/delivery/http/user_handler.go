@@ -1,20 +1,22 @@
 package http
 
 import (
+	"errors"
 	"net/http"
-	"os"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/Hdeee1/go-register-login-profile/internal/domain"
 	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
 	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
 	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
 	"github.com/gin-gonic/gin"
 )
 
 type UserHandler struct {
 	userUseCase domain.UserUsecase
-	tokenBlacklist *jwt.TokenBlacklist
+	tokenBlacklist jwt.Blacklist
 }
 
 type registerResponse struct {
@@ -31,7 +33,22 @@ type loginResponse struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
-func NewUserHandler(u domain.UserUsecase, b *jwt.TokenBlacklist) *UserHandler {
+type mfaRequiredResponse struct {
+	MFARequired	bool	 `json:"mfa_required"`
+	MFAToken	string	 `json:"mfa_token"`
+	MFAMethods	[]string `json:"mfa_methods"`
+}
+
+type sessionResponse struct {
+	Id			string `json:"id"`
+	UserAgent	string `json:"user_agent"`
+	IPAddress	string `json:"ip_address"`
+	CreatedAt	time.Time `json:"created_at"`
+	LastSeenAt	time.Time `json:"last_seen_at"`
+	Current		bool `json:"current"`
+}
+
+func NewUserHandler(u domain.UserUsecase, b jwt.Blacklist) *UserHandler {
 	return &UserHandler{
 		userUseCase: u,
 		tokenBlacklist: b,
@@ -48,7 +65,12 @@ func (h *UserHandler) Register(ctx *gin.Context) {
 
 	user, err := h.userUseCase.Register(newUser, ctx)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		ctx.JSON(http.StatusBadRequest, passwordErrorResponse(err))
+		return
+	}
+
+	if user == nil {
+		ctx.JSON(http.StatusAccepted, response.BuildSuccessResponse("Please check your email to finish signing up", nil))
 		return
 	}
 
@@ -70,45 +92,154 @@ func (h *UserHandler) Login(ctx *gin.Context) {
 		return
 	}
 
-	usr, accTkn, refTkn, err := h.userUseCase.Login(newUser, ctx)
+	newUser.UserAgent = ctx.Request.UserAgent()
+	newUser.IPAddress = ctx.ClientIP()
+
+	result, err := h.userUseCase.Login(newUser, ctx)
+	if writeThrottleError(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", validator.ParseValidatorError(err)))
 		return
 	}
 
+	writeLoginResult(ctx, result)
+}
+
+// passwordErrorResponse lists the broken rules when a new password was
+// rejected by the password policy.
+func passwordErrorResponse(err error) response.APIResponse {
+	var policyErr *utils.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return response.BuildErrorResponseWithDetails("INVALID_PASSWORD", err.Error(), policyErr.Violations)
+	}
+
+	return response.BuildErrorResponse("BAD_REQUEST", err.Error())
+}
+
+// writeThrottleError answers a locked account with 423 and a backed-off
+// address with 429, and reports whether it wrote a response.
+func writeThrottleError(ctx *gin.Context, err error) bool {
+	if errors.Is(err, domain.ErrAccountLocked) {
+		ctx.JSON(http.StatusLocked, response.BuildErrorResponse("ACCOUNT_LOCKED", err.Error()))
+		return true
+	}
+	if errors.Is(err, domain.ErrLoginThrottled) {
+		ctx.JSON(http.StatusTooManyRequests, response.BuildErrorResponse("TOO_MANY_REQUESTS", err.Error()))
+		return true
+	}
+
+	return false
+}
+
+func writeLoginResult(ctx *gin.Context, result *domain.LoginResult) {
+	if result.MFARequired {
+		ctx.JSON(http.StatusOK, response.BuildSuccessResponse("MFA_REQUIRED", mfaRequiredResponse{
+			MFARequired: true,
+			MFAToken: result.MFAToken,
+			MFAMethods: result.MFAMethods,
+		}))
+		return
+	}
+
 	res := loginResponse{
-		Username: usr.Username,
-		Email: usr.Email,
-		AccessToken: accTkn,
-		RefreshToken: refTkn,
+		Username: result.User.Username,
+		Email: result.User.Email,
+		AccessToken: result.AccessToken,
+		RefreshToken: result.RefreshToken,
 	}
 
 	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
 }
 
 func (h *UserHandler) Logout(ctx *gin.Context) {
-	authHeader := ctx.GetHeader("Authorization")
-	if authHeader == "" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Auth header is required"})
-		return 
+	value, exist := ctx.Get("claims")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
-		return 
+	claims := value.(*jwt.CustomClaims)
+
+	if err := h.tokenBlacklist.Add(claims.ID, claims.ExpiresAt.Time, ctx); err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+		return
 	}
 
-	tokenString := parts[1]
+	if !claims.IsServiceAccount() {
+		if err := h.userUseCase.RevokeSession(claims.UserId, claims.SessionId, ctx); err != nil {
+			ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+			return
+		}
+	}
 
-	claims, err := jwt.ValidateToken(tokenString, os.Getenv("JWT_ACCESS_SECRET"))
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"message": "logged out"}))
+}
+
+func (h *UserHandler) LogoutAll(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userId := value.(int)
+
+	if err := h.userUseCase.RevokeAllSessions(userId, ctx); err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"message": "logged out from all devices"}))
+}
+
+func (h *UserHandler) ListSessions(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userId := value.(int)
+	currentSession := ctx.GetString("session_id")
+
+	sessions, err := h.userUseCase.ListSessions(userId, ctx)
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-		return 
+		ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+		return
 	}
 
-	h.tokenBlacklist.AddTokenBlacklist(tokenString, claims.ExpiresAt.Time)
-	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"message": "logged out"}))
+	res := []sessionResponse{}
+	for _, s := range sessions {
+		res = append(res, sessionResponse{
+			Id: s.Id,
+			UserAgent: s.UserAgent,
+			IPAddress: s.IPAddress,
+			CreatedAt: s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			Current: s.Id == currentSession,
+		})
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *UserHandler) RevokeSession(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	userId := value.(int)
+
+	if err := h.userUseCase.RevokeSession(userId, ctx.Param("id"), ctx); err != nil {
+		ctx.JSON(http.StatusNotFound, response.BuildErrorResponse("NOT_FOUND", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"message": "session revoked"}))
 }
 
 func (h *UserHandler) Refresh(ctx *gin.Context) {
@@ -119,13 +250,13 @@ func (h *UserHandler) Refresh(ctx *gin.Context) {
 		return
 	}
 
-	ref, err := h.userUseCase.Refresh(refresh, ctx)
+	accTkn, refTkn, err := h.userUseCase.Refresh(refresh, ctx)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", validator.ParseValidatorError(err)))
 		return
 	}
 
-	ctx.JSON( http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"access_token": ref}))
+	ctx.JSON( http.StatusOK, response.BuildSuccessResponse("OK", gin.H{"access_token": accTkn, "refresh_token": refTkn}))
 }
 
 func (h *UserHandler) GetProfile(ctx *gin.Context) {
@@ -148,6 +279,33 @@ func (h *UserHandler) GetProfile(ctx *gin.Context) {
 			"full_name": user.FullName,
 			"username": user.Username,
 			"email": user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
+			"created_at": user.CreatedAt,
+			"updated_at": user.UpdatedAt,
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *UserHandler) GetUserById(ctx *gin.Context) {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", "invalid user id"))
+		return
+	}
+
+	user, err := h.userUseCase.GetProfile(userId, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, response.BuildErrorResponse("NOT_FOUND", "user not found"))
+		return
+	}
+
+	res := gin.H{
+			"id": user.Id,
+			"full_name": user.FullName,
+			"username": user.Username,
+			"email": user.Email,
+			"email_verified_at": user.EmailVerifiedAt,
 			"created_at": user.CreatedAt,
 			"updated_at": user.UpdatedAt,
 	}
@@ -183,6 +341,64 @@ func (h *UserHandler) UpdateProfile(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", &updatedUser))
 }
 
+func (h *UserHandler) ChangePassword(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	input.UserAgent = ctx.Request.UserAgent()
+	input.IPAddress = ctx.ClientIP()
+
+	accTkn, refTkn, err := h.userUseCase.ChangePassword(value.(int), input, ctx)
+	if writeThrottleError(ctx, err) {
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, passwordErrorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("Your password has been changed", gin.H{"access_token": accTkn, "refresh_token": refTkn}))
+}
+
+func (h *UserHandler) UnlockAccount(ctx *gin.Context) {
+	var input domain.UnlockAccountRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	if err := h.userUseCase.UnlockAccount(input, ctx); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("Your account has been unlocked", nil))
+}
+
+func (h *UserHandler) UnlockUser(ctx *gin.Context) {
+	userId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", "invalid user id"))
+		return
+	}
+
+	if err := h.userUseCase.UnlockUser(userId, ctx); err != nil {
+		ctx.JSON(http.StatusNotFound, response.BuildErrorResponse("NOT_FOUND", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", nil))
+}
+
 func (h *UserHandler) ForgotPassword(ctx *gin.Context) {
 	var forgotPass domain.ForgotPasswordRequest
 	if err := ctx.ShouldBindJSON(&forgotPass); err != nil {
@@ -195,7 +411,7 @@ func (h *UserHandler) ForgotPassword(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The OTP code has been sent to your email", nil))
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The password reset instructions have been sent to your email", nil))
 }
 
 func (h *UserHandler) ResetPassword(ctx *gin.Context) {
@@ -206,7 +422,7 @@ func (h *UserHandler) ResetPassword(ctx *gin.Context) {
 	}
 
 	if err := h.userUseCase.ResetPassword(reset, ctx); err != nil {
-		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		ctx.JSON(http.StatusBadRequest, passwordErrorResponse(err))
 		return
 	}
 	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("The password has been changed", nil))
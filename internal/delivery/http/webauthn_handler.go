@@ -0,0 +1,116 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/response"
+	"github.com/Hdeee1/go-register-login-profile/pkg/validator"
+	"github.com/gin-gonic/gin"
+)
+
+type WebAuthnHandler struct {
+	webauthnUseCase domain.WebAuthnUsecase
+}
+
+func NewWebAuthnHandler(w domain.WebAuthnUsecase) *WebAuthnHandler {
+	return &WebAuthnHandler{webauthnUseCase: w}
+}
+
+func (h *WebAuthnHandler) BeginRegistration(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	res, err := h.webauthnUseCase.BeginRegistration(value.(int), ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *WebAuthnHandler) FinishRegistration(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input domain.WebAuthnRegisterRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	credential, err := h.webauthnUseCase.FinishRegistration(value.(int), input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, response.BuildSuccessResponse("CREATED", credential))
+}
+
+func (h *WebAuthnHandler) ListCredentials(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	credentials, err := h.webauthnUseCase.ListCredentials(value.(int), ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", credentials))
+}
+
+func (h *WebAuthnHandler) DeleteCredential(ctx *gin.Context) {
+	value, exist := ctx.Get("user_id")
+	if !exist {
+		ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.webauthnUseCase.DeleteCredential(value.(int), ctx.Param("id"), ctx); err != nil {
+		ctx.JSON(http.StatusNotFound, response.BuildErrorResponse("NOT_FOUND", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", "passkey deleted"))
+}
+
+func (h *WebAuthnHandler) BeginLogin(ctx *gin.Context) {
+	res, err := h.webauthnUseCase.BeginLogin(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, response.BuildErrorResponse("INTERNAL_SERVER_ERROR", err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.BuildSuccessResponse("OK", res))
+}
+
+func (h *WebAuthnHandler) FinishLogin(ctx *gin.Context) {
+	var input domain.WebAuthnLoginRequest
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.BuildErrorResponse("BAD_REQUEST", validator.ParseValidatorError(err)))
+		return
+	}
+
+	input.UserAgent = ctx.Request.UserAgent()
+	input.IPAddress = ctx.ClientIP()
+
+	result, err := h.webauthnUseCase.FinishLogin(input, ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, response.BuildErrorResponse("UNAUTHORIZED", err.Error()))
+		return
+	}
+
+	writeLoginResult(ctx, result)
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// EmailChange is a request to move an account to a new address. The old
+// address stays in use until the new one is confirmed, and the cancel token
+// sent to the old address can undo the change for a while afterwards, so
+// the old address is kept along with when it was verified.
+type EmailChange struct {
+	Id                 int
+	UserId             int
+	OldEmail           string
+	OldEmailVerifiedAt *time.Time
+	NewEmail           string
+	TokenHash          string
+	CancelTokenHash    string
+	ExpiresAt          time.Time
+	ConfirmedAt        *time.Time
+	CreatedAt          time.Time
+}
+
+type ChangeEmailRequest struct {
+	NewEmail  string `json:"new_email" binding:"required,email"`
+	Password  string `json:"password" binding:"required"`
+	IPAddress string `json:"-"`
+}
+
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailChangeRepository interface {
+	Create(change *EmailChange, ctx context.Context) error
+	GetByTokenHash(tokenHash string, ctx context.Context) (*EmailChange, error)
+	GetByCancelTokenHash(cancelTokenHash string, ctx context.Context) (*EmailChange, error)
+	LatestByUser(userId int, ctx context.Context) (*EmailChange, error)
+	MarkConfirmed(id int, ctx context.Context) (bool, error)
+	Delete(id int, ctx context.Context) (bool, error)
+	DeletePendingByUser(userId int, ctx context.Context) error
+}
+
+type EmailChangeUsecase interface {
+	Request(userId int, input ChangeEmailRequest, ctx context.Context) error
+	Confirm(input EmailChangeTokenRequest, ctx context.Context) error
+	Cancel(input EmailChangeTokenRequest, ctx context.Context) error
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Email verification policies. With none, unverified accounts behave like
+// verified ones; block_login refuses to log them in; restrict lets them log
+// in but keeps them out of account management until they verify.
+const (
+	EmailVerificationNone       = "none"
+	EmailVerificationBlockLogin = "block_login"
+	EmailVerificationRestrict   = "restrict"
+)
+
+type EmailVerification struct {
+	Id        int
+	UserId    int
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type EmailVerificationRepository interface {
+	Create(verification *EmailVerification, ctx context.Context) error
+	GetByTokenHash(tokenHash string, ctx context.Context) (*EmailVerification, error)
+	Delete(id int, ctx context.Context) (bool, error)
+	DeleteByUser(userId int, ctx context.Context) error
+	LatestByUser(userId int, ctx context.Context) (*EmailVerification, error)
+}
+
+type EmailVerificationUsecase interface {
+	Send(user *User, ctx context.Context) error
+	Resend(input ResendVerificationRequest, ctx context.Context) error
+	Confirm(input VerifyEmailRequest, ctx context.Context) error
+	CheckLogin(user *User) error
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAccountLocked  = errors.New("account is temporarily locked")
+	ErrLoginThrottled = errors.New("too many failed login attempts")
+)
+
+// LoginFailure counts failed password logins for an account. The row with an
+// empty IPAddress covers every address and carries the lockout; the others
+// drive the per-address backoff.
+type LoginFailure struct {
+	UserId       int
+	IPAddress    string
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
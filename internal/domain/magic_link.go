@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type MagicLink struct {
+	Id        int
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token     string `json:"token" binding:"required"`
+	UserAgent string `json:"-"`
+	IPAddress string `json:"-"`
+}
+
+type MagicLinkRepository interface {
+	Create(link *MagicLink, ctx context.Context) error
+	GetByTokenHash(tokenHash string, ctx context.Context) (*MagicLink, error)
+	MarkUsed(id int, ctx context.Context) (bool, error)
+	CountSince(email string, since time.Time, ctx context.Context) (int, error)
+}
+
+type MagicLinkUsecase interface {
+	Send(input MagicLinkRequest, ctx context.Context) error
+	Login(input MagicLinkLoginRequest, ctx context.Context) (*LoginResult, error)
+}
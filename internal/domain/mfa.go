@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	MFAMethodTOTP     = "totp"
+	MFAMethodWebAuthn = "webauthn"
+)
+
+type TOTPEnrollment struct {
+	UserId          int
+	SecretEncrypted string
+	ConfirmedAt     *time.Time
+	LastUsedStep    int64
+	CreatedAt       time.Time
+}
+
+type RecoveryCode struct {
+	Id       int
+	UserId   int
+	CodeHash string
+	UsedAt   *time.Time
+}
+
+// MFAChallenge is the pending second step of a login. Only the hash of the
+// token handed to the client is stored.
+type MFAChallenge struct {
+	TokenHash string
+	UserId    int
+	Attempts  int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type TOTPEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode string `json:"qr_code_png"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken      string          `json:"mfa_token" binding:"required"`
+	Code          string          `json:"code"`
+	RecoveryCode  string          `json:"recovery_code"`
+	CeremonyToken string          `json:"ceremony_token"`
+	WebAuthn      json.RawMessage `json:"webauthn"`
+	UserAgent     string          `json:"-"`
+	IPAddress     string          `json:"-"`
+}
+
+type MFAWebAuthnRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFARepository interface {
+	SaveTOTP(enrollment *TOTPEnrollment, ctx context.Context) error
+	GetTOTP(userId int, ctx context.Context) (*TOTPEnrollment, error)
+	ConfirmTOTP(userId int, ctx context.Context) error
+	UseTOTPStep(userId int, step int64, ctx context.Context) (bool, error)
+	DeleteTOTP(userId int, ctx context.Context) error
+	ReplaceRecoveryCodes(userId int, hashes []string, ctx context.Context) error
+	ListUnusedRecoveryCodes(userId int, ctx context.Context) ([]RecoveryCode, error)
+	UseRecoveryCode(id int, ctx context.Context) (bool, error)
+	CreateChallenge(challenge *MFAChallenge, ctx context.Context) error
+	GetChallenge(tokenHash string, ctx context.Context) (*MFAChallenge, error)
+	IncrementChallengeAttempts(tokenHash string, maxAttempts int, ctx context.Context) (bool, error)
+	ConsumeChallenge(tokenHash string, ctx context.Context) (bool, error)
+}
+
+type MFAUsecase interface {
+	EnrollTOTP(userId int, ctx context.Context) (*TOTPEnrollResponse, error)
+	ConfirmTOTP(userId int, input TOTPCodeRequest, ctx context.Context) ([]string, error)
+	DisableTOTP(userId int, input TOTPCodeRequest, ctx context.Context) error
+	RegenerateRecoveryCodes(userId int, input TOTPCodeRequest, ctx context.Context) ([]string, error)
+	BeginWebAuthn(input MFAWebAuthnRequest, ctx context.Context) (*WebAuthnBeginResponse, error)
+	Verify(input MFAVerifyRequest, ctx context.Context) (*LoginResult, error)
+}
@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+var SupportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+
+const (
+	ScopeUsersRead   = "users:read"
+	ScopeUsersUnlock = "users:unlock"
+)
+
+// ServiceScopes can only be granted to service accounts.
+var ServiceScopes = []string{ScopeUsersRead, ScopeUsersUnlock}
+
+type OAuthClient struct {
+	Id             string    `json:"client_id"`
+	SecretHash     string    `json:"-"`
+	Name           string    `json:"client_name"`
+	RedirectURIs   []string  `json:"redirect_uris"`
+	Scopes         []string  `json:"scopes"`
+	Public         bool      `json:"public"`
+	ServiceAccount bool      `json:"service_account"`
+	OwnerId        int       `json:"owner_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type AuthorizationCode struct {
+	CodeHash            string
+	ClientId            string
+	UserId              int
+	RedirectURI         string
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+	UsedAt              *time.Time
+}
+
+type RegisterClientRequest struct {
+	Name         string   `json:"client_name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+type CreateServiceAccountRequest struct {
+	Name   string
+	Scopes []string
+}
+
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type" binding:"required"`
+	ClientId            string `form:"client_id" json:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Approve             bool   `form:"approve" json:"approve"`
+}
+
+type TokenRequest struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientId     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	UserAgent    string `form:"-"`
+	IPAddress    string `form:"-"`
+}
+
+type IntrospectRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientId      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type RevokeRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientId      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// OAuthError carries an RFC 6749 error code so the handler can render the
+// standard {"error": ..., "error_description": ...} body.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+type OAuthRepository interface {
+	CreateClient(client *OAuthClient, ctx context.Context) error
+	GetClient(id string, ctx context.Context) (*OAuthClient, error)
+	SaveAuthorizationCode(code *AuthorizationCode, ctx context.Context) error
+	GetAuthorizationCode(codeHash string, ctx context.Context) (*AuthorizationCode, error)
+	MarkAuthorizationCodeUsed(codeHash string, ctx context.Context) (bool, error)
+}
+
+type OAuthUsecase interface {
+	RegisterClient(ownerId int, input RegisterClientRequest, ctx context.Context) (*OAuthClient, string, error)
+	CreateServiceAccount(input CreateServiceAccountRequest, ctx context.Context) (*OAuthClient, string, error)
+	ValidateAuthorizeRequest(input AuthorizeRequest, ctx context.Context) (*OAuthClient, string, []string, error)
+	Authorize(userId int, input AuthorizeRequest, ctx context.Context) (string, error)
+	Token(input TokenRequest, ctx context.Context) (*TokenResponse, error)
+	UserInfo(userId int, scope string, ctx context.Context) (*UserInfo, error)
+	Introspect(input IntrospectRequest, ctx context.Context) (*TokenInfo, error)
+	Revoke(input RevokeRequest, ctx context.Context) error
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type Session struct {
+	Id             string     `json:"id"`
+	UserId         int        `json:"user_id"`
+	ClientId       string     `json:"client_id,omitempty"`
+	Scope          string     `json:"scope,omitempty"`
+	UserAgent      string     `json:"user_agent"`
+	IPAddress      string     `json:"ip_address"`
+	RefreshTokenId string     `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+type SessionRepository interface {
+	Create(session *Session, ctx context.Context) error
+	GetById(id string, ctx context.Context) (*Session, error)
+	ListActiveByUser(userId int, ctx context.Context) ([]Session, error)
+	Touch(id, refreshTokenId string, ctx context.Context) error
+	Revoke(id string, ctx context.Context) error
+	RevokeAllByUser(userId int, ctx context.Context) error
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type RefreshToken struct {
+	Id         string     `json:"id"`
+	UserId     int        `json:"user_id"`
+	FamilyId   string     `json:"family_id"`
+	ReplacedBy string     `json:"replaced_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TokenInfo describes a token that is currently active. Its JSON form is the
+// RFC 7662 introspection response.
+type TokenInfo struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientId  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	TokenId   string   `json:"jti,omitempty"`
+	UserId    int      `json:"-"`
+	SessionId string   `json:"-"`
+}
+
+type RefreshTokenRepository interface {
+	Create(token *RefreshToken, ctx context.Context) error
+	GetById(id string, ctx context.Context) (*RefreshToken, error)
+	Rotate(id, replacedBy string, ctx context.Context) (bool, error)
+	RevokeFamily(familyId string, ctx context.Context) error
+	RevokeByUser(userId int, ctx context.Context) error
+}
@@ -6,46 +6,86 @@ import (
 )
 
 type User struct {
-	Id        int       `json:"id" `
-	FullName  string    `json:"full_name"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	CreatedAt time.Time `json:"created_at" `
-	UpdatedAt time.Time `json:"updated_at" `
+	Id              int        `json:"id" `
+	FullName        string     `json:"full_name"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Password        string     `json:"password"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TokenVersion    int        `json:"-"`
+	CreatedAt       time.Time  `json:"created_at" `
+	UpdatedAt       time.Time  `json:"updated_at" `
 }
 
 type RegisterRequest struct {
 	FullName string `json:"full_name" binding:"required"`
 	Username string `json:"username" binding:"required,min=3"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=8"`
+	Password string `json:"password" binding:"required"`
 }
 
 type LoginRequest struct {
 	Email        string `json:"email" binding:"required,email"`
-	Password     string `json:"password" binding:"required,min=8"`
+	Password     string `json:"password" binding:"required"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	UserAgent    string `json:"-"`
+	IPAddress    string `json:"-"`
+}
+
+// LoginResult carries either the issued tokens or, when the account has a
+// second factor, the token for the pending MFA challenge.
+type LoginResult struct {
+	User         *User
+	AccessToken  string
+	RefreshToken string
+	MFARequired  bool
+	MFAToken     string
+	MFAMethods   []string
 }
 
 type UpdateProfileRequest struct {
 	Username string `json:"username"`
-	Password string `json:"password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+	UserAgent       string `json:"-"`
+	IPAddress       string `json:"-"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" binding:"required"`
+	ClientId     string `json:"-"`
 }
 
+const (
+	ResetMethodOTP  = "otp"
+	ResetMethodLink = "link"
+)
+
 type ForgotPasswordRequest struct {
-	Email string `json:"email" binding:"required,email"`
+	Email  string `json:"email" binding:"required,email"`
+	Method string `json:"method" binding:"omitempty,oneof=otp link"`
 }
 
 type ResetPasswordRequest struct {
-	Email       string `json:"email" binding:"required,email"`
-	OTP         string `json:"otp_code" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=8"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	OTP         string `json:"otp_code"`
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// PasswordReset is a pending reset, either a short OTP typed by the user or
+// a long token sent as a link. Only the hash of the code is stored.
+type PasswordReset struct {
+	Email     string
+	Method    string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
 }
 
 type UserRepository interface {
@@ -54,17 +94,44 @@ type UserRepository interface {
 	GetById(id int) (*User, error)
 	FindByEmailOrUsername(email, username string) (*User, error)
 	Update(user *User, ctx context.Context) error
-	SaveOTP(email, otp string, expiresAt time.Time, ctx context.Context) error
-	FindOTP(email string, ctx context.Context) (string, time.Time, error)
-	DeleteOTP(email string, ctx context.Context) error
+	UpdatePassword(userId int, password string, ctx context.Context) error
+	RehashPassword(userId int, oldHash, newHash string, ctx context.Context) error
+	GetTokenVersion(userId int, ctx context.Context) (int, error)
+	MarkEmailVerified(userId int, email string, ctx context.Context) (bool, error)
+	UpdateEmail(userId int, oldEmail, newEmail string, ctx context.Context) (bool, error)
+	RestoreEmail(userId int, currentEmail, email string, verifiedAt *time.Time, ctx context.Context) (bool, error)
+	SavePasswordReset(reset *PasswordReset, ctx context.Context) error
+	FindPasswordReset(email string, ctx context.Context) (*PasswordReset, error)
+	FindPasswordResetByHash(codeHash string, ctx context.Context) (*PasswordReset, error)
+	IncrementPasswordResetAttempts(email string, maxAttempts int, ctx context.Context) (bool, error)
+	DeletePasswordReset(email, codeHash string, ctx context.Context) (bool, error)
+	GetLoginFailure(userId int, ipAddress string, ctx context.Context) (*LoginFailure, error)
+	RecordLoginFailure(userId int, ipAddress string, windowStart time.Time, ctx context.Context) (*LoginFailure, error)
+	LockAccount(userId int, until time.Time, unlockTokenHash string, ctx context.Context) error
+	FindLockByUnlockToken(unlockTokenHash string, ctx context.Context) (*LoginFailure, error)
+	ResetLoginFailures(userId int, ipAddress string, ctx context.Context) error
+	ClearLoginFailures(userId int, ctx context.Context) error
 }
 
 type UserUsecase interface {
 	Register(user RegisterRequest, ctx context.Context) (*User, error)
-	Login(user LoginRequest, ctx context.Context) (*User, string, string, error)
+	Login(user LoginRequest, ctx context.Context) (*LoginResult, error)
 	GetProfile(userId int, ctx context.Context) (*User, error)
-	Refresh(input RefreshTokenRequest, ctx context.Context) (string, error)
+	Refresh(input RefreshTokenRequest, ctx context.Context) (string, string, error)
+	CompleteLogin(user *User, session *Session, ctx context.Context) (*LoginResult, error)
+	CompleteMultiFactorLogin(user *User, session *Session, ctx context.Context) (*LoginResult, error)
+	StartSession(session *Session, ctx context.Context) (string, string, error)
+	IntrospectToken(token string, ctx context.Context) (*TokenInfo, error)
+	RevokeToken(token string, ctx context.Context) error
 	UpdateProfile(userId int, input UpdateProfileRequest, ctx context.Context) (*User, error)
+	ChangePassword(userId int, input ChangePasswordRequest, ctx context.Context) (string, string, error)
 	ForgotPassword(input ForgotPasswordRequest, ctx context.Context) error
 	ResetPassword(input ResetPasswordRequest, ctx context.Context) error
+	ListSessions(userId int, ctx context.Context) ([]Session, error)
+	RevokeSession(userId int, sessionId string, ctx context.Context) error
+	RevokeAllSessions(userId int, ctx context.Context) error
+	CheckLoginThrottle(userId int, ipAddress string, ctx context.Context) error
+	RecordLoginFailure(userId int, ipAddress string, ctx context.Context) error
+	UnlockAccount(input UnlockAccountRequest, ctx context.Context) error
+	UnlockUser(userId int, ctx context.Context) error
 }
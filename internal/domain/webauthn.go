@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	CeremonyRegistration = "registration"
+	CeremonyLogin        = "login"
+	CeremonySecondFactor = "second_factor"
+)
+
+// WebAuthnCredential is a registered passkey. Data holds the JSON encoded
+// credential record from the WebAuthn library; SignCount is kept in its own
+// column so it can be inspected without decoding.
+type WebAuthnCredential struct {
+	Id         string     `json:"id"`
+	UserId     int        `json:"-"`
+	Name       string     `json:"name"`
+	Data       []byte     `json:"-"`
+	SignCount  uint32     `json:"sign_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnCeremony is the server side state of a pending registration or
+// authentication, looked up by the hash of the token handed to the client.
+type WebAuthnCeremony struct {
+	TokenHash string
+	UserId    int
+	Kind      string
+	Data      []byte
+	ExpiresAt time.Time
+}
+
+type WebAuthnBeginResponse struct {
+	CeremonyToken string `json:"ceremony_token"`
+	Options       any    `json:"options"`
+}
+
+type WebAuthnRegisterRequest struct {
+	CeremonyToken string          `json:"ceremony_token" binding:"required"`
+	Name          string          `json:"name"`
+	Credential    json.RawMessage `json:"credential" binding:"required"`
+}
+
+type WebAuthnLoginRequest struct {
+	CeremonyToken string          `json:"ceremony_token" binding:"required"`
+	Credential    json.RawMessage `json:"credential" binding:"required"`
+	UserAgent     string          `json:"-"`
+	IPAddress     string          `json:"-"`
+}
+
+type WebAuthnRepository interface {
+	CreateCredential(credential *WebAuthnCredential, ctx context.Context) error
+	GetCredential(id string, ctx context.Context) (*WebAuthnCredential, error)
+	ListCredentialsByUser(userId int, ctx context.Context) ([]WebAuthnCredential, error)
+	UpdateCredential(credential *WebAuthnCredential, ctx context.Context) error
+	DeleteCredential(userId int, id string, ctx context.Context) (bool, error)
+	SaveCeremony(ceremony *WebAuthnCeremony, ctx context.Context) error
+	TakeCeremony(tokenHash string, ctx context.Context) (*WebAuthnCeremony, error)
+}
+
+type WebAuthnUsecase interface {
+	BeginRegistration(userId int, ctx context.Context) (*WebAuthnBeginResponse, error)
+	FinishRegistration(userId int, input WebAuthnRegisterRequest, ctx context.Context) (*WebAuthnCredential, error)
+	ListCredentials(userId int, ctx context.Context) ([]WebAuthnCredential, error)
+	DeleteCredential(userId int, id string, ctx context.Context) error
+	BeginLogin(ctx context.Context) (*WebAuthnBeginResponse, error)
+	FinishLogin(input WebAuthnLoginRequest, ctx context.Context) (*LoginResult, error)
+	BeginSecondFactor(userId int, ctx context.Context) (*WebAuthnBeginResponse, error)
+	VerifySecondFactor(userId int, ceremonyToken string, credential json.RawMessage, ctx context.Context) error
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLEmailChangeRepository struct {
+	db *sql.DB
+}
+
+const emailChangeColumns = "id, user_id, old_email, old_email_verified_at, new_email, token_hash, cancel_token_hash, expires_at, confirmed_at, created_at"
+
+func NewEmailChangeRepository(db *sql.DB) (domain.EmailChangeRepository, error) {
+	return &mySQLEmailChangeRepository{db: db}, nil
+}
+
+func (m *mySQLEmailChangeRepository) Create(change *domain.EmailChange, ctx context.Context) error {
+	query := "INSERT INTO email_changes (user_id, old_email, old_email_verified_at, new_email, token_hash, cancel_token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	res, err := m.db.Exec(query, change.UserId, change.OldEmail, change.OldEmailVerifiedAt, change.NewEmail, change.TokenHash, change.CancelTokenHash, change.ExpiresAt, change.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	change.Id = int(id)
+
+	return nil
+}
+
+func (m *mySQLEmailChangeRepository) GetByTokenHash(tokenHash string, ctx context.Context) (*domain.EmailChange, error) {
+	query := "SELECT " + emailChangeColumns + " FROM email_changes WHERE token_hash = ?"
+	return scanEmailChange(m.db.QueryRow(query, tokenHash))
+}
+
+func (m *mySQLEmailChangeRepository) GetByCancelTokenHash(cancelTokenHash string, ctx context.Context) (*domain.EmailChange, error) {
+	query := "SELECT " + emailChangeColumns + " FROM email_changes WHERE cancel_token_hash = ?"
+	return scanEmailChange(m.db.QueryRow(query, cancelTokenHash))
+}
+
+func (m *mySQLEmailChangeRepository) LatestByUser(userId int, ctx context.Context) (*domain.EmailChange, error) {
+	query := "SELECT " + emailChangeColumns + " FROM email_changes WHERE user_id = ? ORDER BY created_at DESC LIMIT 1"
+	return scanEmailChange(m.db.QueryRow(query, userId))
+}
+
+func (m *mySQLEmailChangeRepository) MarkConfirmed(id int, ctx context.Context) (bool, error) {
+	res, err := m.db.Exec("UPDATE email_changes SET confirmed_at = CURRENT_TIMESTAMP WHERE id = ? AND confirmed_at IS NULL", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLEmailChangeRepository) Delete(id int, ctx context.Context) (bool, error) {
+	res, err := m.db.Exec("DELETE FROM email_changes WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLEmailChangeRepository) DeletePendingByUser(userId int, ctx context.Context) error {
+	_, err := m.db.Exec("DELETE FROM email_changes WHERE user_id = ? AND confirmed_at IS NULL", userId)
+	return err
+}
+
+func scanEmailChange(row rowScanner) (*domain.EmailChange, error) {
+	var change domain.EmailChange
+	var oldEmailVerifiedAt, confirmedAt sql.NullTime
+
+	if err := row.Scan(
+		&change.Id,
+		&change.UserId,
+		&change.OldEmail,
+		&oldEmailVerifiedAt,
+		&change.NewEmail,
+		&change.TokenHash,
+		&change.CancelTokenHash,
+		&change.ExpiresAt,
+		&confirmedAt,
+		&change.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if oldEmailVerifiedAt.Valid {
+		change.OldEmailVerifiedAt = &oldEmailVerifiedAt.Time
+	}
+	if confirmedAt.Valid {
+		change.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &change, nil
+}
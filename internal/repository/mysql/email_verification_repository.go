@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLEmailVerificationRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationRepository(db *sql.DB) (domain.EmailVerificationRepository, error) {
+	return &mySQLEmailVerificationRepository{db: db}, nil
+}
+
+func (m *mySQLEmailVerificationRepository) Create(verification *domain.EmailVerification, ctx context.Context) error {
+	query := "INSERT INTO email_verifications (user_id, email, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)"
+	res, err := m.db.Exec(query, verification.UserId, verification.Email, verification.TokenHash, verification.ExpiresAt, verification.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	verification.Id = int(id)
+
+	return nil
+}
+
+func (m *mySQLEmailVerificationRepository) GetByTokenHash(tokenHash string, ctx context.Context) (*domain.EmailVerification, error) {
+	query := "SELECT id, user_id, email, token_hash, expires_at, created_at FROM email_verifications WHERE token_hash = ?"
+	return scanEmailVerification(m.db.QueryRow(query, tokenHash))
+}
+
+func (m *mySQLEmailVerificationRepository) Delete(id int, ctx context.Context) (bool, error) {
+	res, err := m.db.Exec("DELETE FROM email_verifications WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLEmailVerificationRepository) DeleteByUser(userId int, ctx context.Context) error {
+	_, err := m.db.Exec("DELETE FROM email_verifications WHERE user_id = ?", userId)
+	return err
+}
+
+func (m *mySQLEmailVerificationRepository) LatestByUser(userId int, ctx context.Context) (*domain.EmailVerification, error) {
+	query := "SELECT id, user_id, email, token_hash, expires_at, created_at FROM email_verifications WHERE user_id = ? ORDER BY created_at DESC LIMIT 1"
+	return scanEmailVerification(m.db.QueryRow(query, userId))
+}
+
+func scanEmailVerification(row rowScanner) (*domain.EmailVerification, error) {
+	var verification domain.EmailVerification
+
+	if err := row.Scan(
+		&verification.Id,
+		&verification.UserId,
+		&verification.Email,
+		&verification.TokenHash,
+		&verification.ExpiresAt,
+		&verification.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return &verification, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLMagicLinkRepository struct {
+	db *sql.DB
+}
+
+func NewMagicLinkRepository(db *sql.DB) (domain.MagicLinkRepository, error) {
+	return &mySQLMagicLinkRepository{db: db}, nil
+}
+
+func (m *mySQLMagicLinkRepository) Create(link *domain.MagicLink, ctx context.Context) error {
+	query := "INSERT INTO magic_links (email, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)"
+	res, err := m.db.Exec(query, link.Email, link.TokenHash, link.ExpiresAt, link.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	link.Id = int(id)
+
+	return nil
+}
+
+func (m *mySQLMagicLinkRepository) GetByTokenHash(tokenHash string, ctx context.Context) (*domain.MagicLink, error) {
+	query := "SELECT id, email, token_hash, expires_at, used_at, created_at FROM magic_links WHERE token_hash = ?"
+	row := m.db.QueryRow(query, tokenHash)
+
+	var link domain.MagicLink
+	var usedAt sql.NullTime
+
+	if err := row.Scan(
+		&link.Id,
+		&link.Email,
+		&link.TokenHash,
+		&link.ExpiresAt,
+		&usedAt,
+		&link.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		link.UsedAt = &usedAt.Time
+	}
+
+	return &link, nil
+}
+
+func (m *mySQLMagicLinkRepository) MarkUsed(id int, ctx context.Context) (bool, error) {
+	query := "UPDATE magic_links SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL"
+	res, err := m.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLMagicLinkRepository) CountSince(email string, since time.Time, ctx context.Context) (int, error) {
+	query := "SELECT COUNT(*) FROM magic_links WHERE email = ? AND created_at > ?"
+
+	var count int
+	if err := m.db.QueryRow(query, email, since).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLMFARepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) (domain.MFARepository, error) {
+	return &mySQLMFARepository{db: db}, nil
+}
+
+func (m *mySQLMFARepository) SaveTOTP(enrollment *domain.TOTPEnrollment, ctx context.Context) error {
+	query := "INSERT INTO user_totp (user_id, secret_encrypted) VALUES (?, ?) ON DUPLICATE KEY UPDATE secret_encrypted = ?, confirmed_at = NULL, last_used_step = 0, created_at = CURRENT_TIMESTAMP"
+	_, err := m.db.Exec(query, enrollment.UserId, enrollment.SecretEncrypted, enrollment.SecretEncrypted)
+	return err
+}
+
+func (m *mySQLMFARepository) GetTOTP(userId int, ctx context.Context) (*domain.TOTPEnrollment, error) {
+	query := "SELECT user_id, secret_encrypted, confirmed_at, last_used_step, created_at FROM user_totp WHERE user_id = ?"
+	row := m.db.QueryRow(query, userId)
+
+	var enrollment domain.TOTPEnrollment
+	var confirmedAt sql.NullTime
+
+	if err := row.Scan(
+		&enrollment.UserId,
+		&enrollment.SecretEncrypted,
+		&confirmedAt,
+		&enrollment.LastUsedStep,
+		&enrollment.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		enrollment.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return &enrollment, nil
+}
+
+func (m *mySQLMFARepository) ConfirmTOTP(userId int, ctx context.Context) error {
+	query := "UPDATE user_totp SET confirmed_at = CURRENT_TIMESTAMP WHERE user_id = ?"
+	_, err := m.db.Exec(query, userId)
+	return err
+}
+
+// UseTOTPStep records step as used only if it is newer than the last one,
+// so a code can never be replayed within its validity window.
+func (m *mySQLMFARepository) UseTOTPStep(userId int, step int64, ctx context.Context) (bool, error) {
+	query := "UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?"
+	res, err := m.db.Exec(query, step, userId, step)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLMFARepository) DeleteTOTP(userId int, ctx context.Context) error {
+	if _, err := m.db.Exec("DELETE FROM user_totp WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+
+	_, err := m.db.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userId)
+	return err
+}
+
+func (m *mySQLMFARepository) ReplaceRecoveryCodes(userId int, hashes []string, ctx context.Context) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userId); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)", userId, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m *mySQLMFARepository) ListUnusedRecoveryCodes(userId int, ctx context.Context) ([]domain.RecoveryCode, error) {
+	query := "SELECT id, user_id, code_hash FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL"
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []domain.RecoveryCode{}
+	for rows.Next() {
+		var code domain.RecoveryCode
+		if err := rows.Scan(&code.Id, &code.UserId, &code.CodeHash); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+func (m *mySQLMFARepository) UseRecoveryCode(id int, ctx context.Context) (bool, error) {
+	query := "UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE id = ? AND used_at IS NULL"
+	res, err := m.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLMFARepository) CreateChallenge(challenge *domain.MFAChallenge, ctx context.Context) error {
+	query := "INSERT INTO mfa_challenges (token_hash, user_id, expires_at) VALUES (?, ?, ?)"
+	_, err := m.db.Exec(query, challenge.TokenHash, challenge.UserId, challenge.ExpiresAt)
+	return err
+}
+
+func (m *mySQLMFARepository) GetChallenge(tokenHash string, ctx context.Context) (*domain.MFAChallenge, error) {
+	query := "SELECT token_hash, user_id, attempts, expires_at, used_at FROM mfa_challenges WHERE token_hash = ?"
+	row := m.db.QueryRow(query, tokenHash)
+
+	var challenge domain.MFAChallenge
+	var usedAt sql.NullTime
+
+	if err := row.Scan(
+		&challenge.TokenHash,
+		&challenge.UserId,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&usedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		challenge.UsedAt = &usedAt.Time
+	}
+
+	return &challenge, nil
+}
+
+// IncrementChallengeAttempts spends one guess. It reports false once the
+// challenge has used up maxAttempts, so concurrent guesses cannot exceed the
+// limit.
+func (m *mySQLMFARepository) IncrementChallengeAttempts(tokenHash string, maxAttempts int, ctx context.Context) (bool, error) {
+	query := "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE token_hash = ? AND attempts < ?"
+	res, err := m.db.Exec(query, tokenHash, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLMFARepository) ConsumeChallenge(tokenHash string, ctx context.Context) (bool, error) {
+	query := "UPDATE mfa_challenges SET used_at = CURRENT_TIMESTAMP WHERE token_hash = ? AND used_at IS NULL"
+	res, err := m.db.Exec(query, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLOAuthRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthRepository(db *sql.DB) (domain.OAuthRepository, error) {
+	return &mySQLOAuthRepository{db: db}, nil
+}
+
+func (m *mySQLOAuthRepository) CreateClient(client *domain.OAuthClient, ctx context.Context) error {
+	redirectURIs, err := json.Marshal(client.RedirectURIs)
+	if err != nil {
+		return err
+	}
+
+	ownerId := sql.NullInt64{Int64: int64(client.OwnerId), Valid: client.OwnerId != 0}
+
+	query := "INSERT INTO oauth_clients (id, secret_hash, name, redirect_uris, scopes, public, service_account, owner_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = m.db.Exec(query, client.Id, client.SecretHash, client.Name, string(redirectURIs), strings.Join(client.Scopes, " "), client.Public, client.ServiceAccount, ownerId)
+	return err
+}
+
+func (m *mySQLOAuthRepository) GetClient(id string, ctx context.Context) (*domain.OAuthClient, error) {
+	query := "SELECT id, secret_hash, name, redirect_uris, scopes, public, service_account, owner_id, created_at FROM oauth_clients WHERE id = ?"
+	row := m.db.QueryRow(query, id)
+
+	var client domain.OAuthClient
+	var redirectURIs, scopes string
+	var ownerId sql.NullInt64
+
+	if err := row.Scan(
+		&client.Id,
+		&client.SecretHash,
+		&client.Name,
+		&redirectURIs,
+		&scopes,
+		&client.Public,
+		&client.ServiceAccount,
+		&ownerId,
+		&client.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	client.OwnerId = int(ownerId.Int64)
+	if err := json.Unmarshal([]byte(redirectURIs), &client.RedirectURIs); err != nil {
+		return nil, err
+	}
+	client.Scopes = strings.Fields(scopes)
+
+	return &client, nil
+}
+
+func (m *mySQLOAuthRepository) SaveAuthorizationCode(code *domain.AuthorizationCode, ctx context.Context) error {
+	query := "INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, code_challenge_method, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := m.db.Exec(query, code.CodeHash, code.ClientId, code.UserId, code.RedirectURI, code.Scope, code.Nonce, code.CodeChallenge, code.CodeChallengeMethod, code.ExpiresAt)
+	return err
+}
+
+func (m *mySQLOAuthRepository) GetAuthorizationCode(codeHash string, ctx context.Context) (*domain.AuthorizationCode, error) {
+	query := "SELECT code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, code_challenge_method, expires_at, used_at FROM oauth_authorization_codes WHERE code_hash = ?"
+	row := m.db.QueryRow(query, codeHash)
+
+	var code domain.AuthorizationCode
+	var usedAt sql.NullTime
+
+	if err := row.Scan(
+		&code.CodeHash,
+		&code.ClientId,
+		&code.UserId,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.CodeChallengeMethod,
+		&code.ExpiresAt,
+		&usedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		code.UsedAt = &usedAt.Time
+	}
+
+	return &code, nil
+}
+
+func (m *mySQLOAuthRepository) MarkAuthorizationCodeUsed(codeHash string, ctx context.Context) (bool, error) {
+	query := "UPDATE oauth_authorization_codes SET used_at = CURRENT_TIMESTAMP WHERE code_hash = ? AND used_at IS NULL"
+	res, err := m.db.Exec(query, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
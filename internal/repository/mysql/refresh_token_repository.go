@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLRefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) (domain.RefreshTokenRepository, error) {
+	return &mySQLRefreshTokenRepository{db: db}, nil
+}
+
+func (m *mySQLRefreshTokenRepository) Create(token *domain.RefreshToken, ctx context.Context) error {
+	query := "INSERT INTO refresh_tokens (id, user_id, family_id, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := m.db.Exec(query, token.Id, token.UserId, token.FamilyId, token.ExpiresAt)
+	return err
+}
+
+func (m *mySQLRefreshTokenRepository) GetById(id string, ctx context.Context) (*domain.RefreshToken, error) {
+	query := "SELECT id, user_id, family_id, replaced_by, expires_at, revoked_at, created_at FROM refresh_tokens WHERE id = ?"
+	row := m.db.QueryRow(query, id)
+
+	var token domain.RefreshToken
+	var replacedBy sql.NullString
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(
+		&token.Id,
+		&token.UserId,
+		&token.FamilyId,
+		&replacedBy,
+		&token.ExpiresAt,
+		&revokedAt,
+		&token.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	token.ReplacedBy = replacedBy.String
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// Rotate marks the token as replaced only if it is still active, so two
+// concurrent refreshes with the same token cannot both succeed.
+func (m *mySQLRefreshTokenRepository) Rotate(id, replacedBy string, ctx context.Context) (bool, error) {
+	query := "UPDATE refresh_tokens SET replaced_by = ? WHERE id = ? AND replaced_by IS NULL AND revoked_at IS NULL"
+	res, err := m.db.Exec(query, replacedBy, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLRefreshTokenRepository) RevokeFamily(familyId string, ctx context.Context) error {
+	query := "UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = ? AND revoked_at IS NULL"
+	_, err := m.db.Exec(query, familyId)
+	return err
+}
+
+func (m *mySQLRefreshTokenRepository) RevokeByUser(userId int, ctx context.Context) error {
+	query := "UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL"
+	_, err := m.db.Exec(query, userId)
+	return err
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLSessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) (domain.SessionRepository, error) {
+	return &mySQLSessionRepository{db: db}, nil
+}
+
+func (m *mySQLSessionRepository) Create(session *domain.Session, ctx context.Context) error {
+	query := "INSERT INTO sessions (id, user_id, client_id, scope, user_agent, ip_address, refresh_jti) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := m.db.Exec(query, session.Id, session.UserId, session.ClientId, session.Scope, session.UserAgent, session.IPAddress, session.RefreshTokenId)
+	return err
+}
+
+func (m *mySQLSessionRepository) GetById(id string, ctx context.Context) (*domain.Session, error) {
+	query := "SELECT id, user_id, client_id, scope, user_agent, ip_address, refresh_jti, created_at, last_seen_at, revoked_at FROM sessions WHERE id = ?"
+	row := m.db.QueryRow(query, id)
+
+	return scanSession(row)
+}
+
+func (m *mySQLSessionRepository) ListActiveByUser(userId int, ctx context.Context) ([]domain.Session, error) {
+	query := "SELECT id, user_id, client_id, scope, user_agent, ip_address, refresh_jti, created_at, last_seen_at, revoked_at FROM sessions WHERE user_id = ? AND revoked_at IS NULL ORDER BY last_seen_at DESC"
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []domain.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (m *mySQLSessionRepository) Touch(id, refreshTokenId string, ctx context.Context) error {
+	query := "UPDATE sessions SET refresh_jti = ?, last_seen_at = CURRENT_TIMESTAMP WHERE id = ?"
+	_, err := m.db.Exec(query, refreshTokenId, id)
+	return err
+}
+
+func (m *mySQLSessionRepository) Revoke(id string, ctx context.Context) error {
+	query := "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL"
+	_, err := m.db.Exec(query, id)
+	return err
+}
+
+func (m *mySQLSessionRepository) RevokeAllByUser(userId int, ctx context.Context) error {
+	query := "UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = ? AND revoked_at IS NULL"
+	_, err := m.db.Exec(query, userId)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (*domain.Session, error) {
+	var session domain.Session
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(
+		&session.Id,
+		&session.UserId,
+		&session.ClientId,
+		&session.Scope,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.RefreshTokenId,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return &session, nil
+}
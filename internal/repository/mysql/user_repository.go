@@ -34,51 +34,32 @@ func (m *mySQLUserRepository) Create(user *domain.User, ctx context.Context) err
 	return nil
 }
 
-func (m *mySQLUserRepository) GetByEmail(user *domain.User, ctx context.Context) error {
-	query := "SELECT id, full_name, username, email, password, created_at, updated_at FROM users WHERE email = ?"
-	row := m.db.QueryRow(query, user.Email)
+const userColumns = "id, full_name, username, email, password, email_verified_at, token_version, created_at, updated_at"
 
-	if err := row.Scan(
-		&user.Id,
-		&user.FullName,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	); err != nil {
+func (m *mySQLUserRepository) GetByEmail(user *domain.User, ctx context.Context) error {
+	query := "SELECT " + userColumns + " FROM users WHERE email = ?"
+	found, err := scanUser(m.db.QueryRow(query, user.Email))
+	if err != nil {
 		return err
 	}
 
+	*user = *found
 	return nil
 }
 
 func (m *mySQLUserRepository) GetById(id int) (*domain.User, error) {
-	query := "SELECT id, full_name, username, email, password, created_at, updated_at FROM users WHERE id = ?"
-	row := m.db.QueryRow(query, id)
-
-	var user domain.User
-
-	if err := row.Scan(
-		&user.Id,
-		&user.FullName,
-		&user.Username,
-		&user.Email,
-		&user.Password,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	); err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	query := "SELECT " + userColumns + " FROM users WHERE id = ?"
+	return scanUser(m.db.QueryRow(query, id))
 }
 
 func (m *mySQLUserRepository) FindByEmailOrUsername(email, username string) (*domain.User, error) {
-	query := "SELECT id, full_name, username, email, password, created_at, updated_at FROM users WHERE email = ? OR username = ?"
-	row := m.db.QueryRow(query, email, username)
+	query := "SELECT " + userColumns + " FROM users WHERE email = ? OR username = ?"
+	return scanUser(m.db.QueryRow(query, email, username))
+}
 
+func scanUser(row rowScanner) (*domain.User, error) {
 	var user domain.User
+	var emailVerifiedAt sql.NullTime
 
 	if err := row.Scan(
 		&user.Id,
@@ -86,12 +67,18 @@ func (m *mySQLUserRepository) FindByEmailOrUsername(email, username string) (*do
 		&user.Username,
 		&user.Email,
 		&user.Password,
+		&emailVerifiedAt,
+		&user.TokenVersion,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
 		return nil, err
 	}
 
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+
 	return &user, nil
 }
 
@@ -104,11 +91,6 @@ func (m *mySQLUserRepository) Update(user *domain.User, ctx context.Context) err
 		args = append(args, user.Username)
 	}
 
-	if user.Password != "" {
-		fields = append(fields, "password = ?")
-		args = append(args, user.Password)
-	}
-
 	if len(fields) == 0 {
 		return errors.New("no fields to update")
 	}
@@ -124,9 +106,86 @@ func (m *mySQLUserRepository) Update(user *domain.User, ctx context.Context) err
 	return nil
 }
 
-func (m *mySQLUserRepository) SaveOTP(email, otp string, expiresAt time.Time, ctx context.Context) error {
-	query := "INSERT INTO password_resets (email, otp, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE otp = ?, expires_at = ?"
-	_, err := m.db.Exec(query, email, otp, expiresAt, otp, expiresAt)
+// UpdatePassword stores a new password hash and bumps the token version, so
+// every token issued before the change stops being accepted.
+func (m *mySQLUserRepository) UpdatePassword(userId int, password string, ctx context.Context) error {
+	_, err := m.db.Exec("UPDATE users SET password = ?, token_version = token_version + 1 WHERE id = ?", password, userId)
+	return err
+}
+
+// RehashPassword swaps in a stronger hash of the same password. It leaves the
+// token version alone and does nothing if the password changed meanwhile.
+func (m *mySQLUserRepository) RehashPassword(userId int, oldHash, newHash string, ctx context.Context) error {
+	_, err := m.db.Exec("UPDATE users SET password = ? WHERE id = ? AND password = ?", newHash, userId, oldHash)
+	return err
+}
+
+func (m *mySQLUserRepository) GetTokenVersion(userId int, ctx context.Context) (int, error) {
+	var version int
+	if err := m.db.QueryRow("SELECT token_version FROM users WHERE id = ?", userId).Scan(&version); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// MarkEmailVerified only succeeds while the account still has the address
+// that was verified, so a stale link cannot verify a changed email.
+func (m *mySQLUserRepository) MarkEmailVerified(userId int, email string, ctx context.Context) (bool, error) {
+	query := "UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = ? AND email = ?"
+	res, err := m.db.Exec(query, userId, email)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// UpdateEmail moves the account from oldEmail to newEmail. The address was
+// proven by the link that triggered the change, so it counts as verified.
+func (m *mySQLUserRepository) UpdateEmail(userId int, oldEmail, newEmail string, ctx context.Context) (bool, error) {
+	query := "UPDATE users SET email = ?, email_verified_at = CURRENT_TIMESTAMP WHERE id = ? AND email = ?"
+	res, err := m.db.Exec(query, newEmail, userId, oldEmail)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// RestoreEmail puts back an address replaced by UpdateEmail together with
+// its original verification time, which may be NULL.
+func (m *mySQLUserRepository) RestoreEmail(userId int, currentEmail, email string, verifiedAt *time.Time, ctx context.Context) (bool, error) {
+	query := "UPDATE users SET email = ?, email_verified_at = ? WHERE id = ? AND email = ?"
+	res, err := m.db.Exec(query, email, verifiedAt, userId, currentEmail)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLUserRepository) SavePasswordReset(reset *domain.PasswordReset, ctx context.Context) error {
+	query := "INSERT INTO password_resets (email, method, code_hash, attempts, expires_at, created_at) VALUES (?, ?, ?, 0, ?, ?) ON DUPLICATE KEY UPDATE method = ?, code_hash = ?, attempts = 0, expires_at = ?, created_at = ?"
+	_, err := m.db.Exec(query,
+		reset.Email, reset.Method, reset.CodeHash, reset.ExpiresAt, reset.CreatedAt,
+		reset.Method, reset.CodeHash, reset.ExpiresAt, reset.CreatedAt,
+	)
 	if err != nil {
 		return err
 	}
@@ -134,25 +193,134 @@ func (m *mySQLUserRepository) SaveOTP(email, otp string, expiresAt time.Time, ct
 	return nil
 }
 
-func (m *mySQLUserRepository) FindOTP(email string, ctx context.Context) (string, time.Time, error) {
-	query := "SELECT otp, expires_at FROM password_resets WHERE email = ?"
-	row := m.db.QueryRow(query, email)
+func (m *mySQLUserRepository) FindPasswordReset(email string, ctx context.Context) (*domain.PasswordReset, error) {
+	query := "SELECT email, method, code_hash, attempts, expires_at, created_at FROM password_resets WHERE email = ?"
+	return scanPasswordReset(m.db.QueryRow(query, email))
+}
 
-	var otp string
-	var expires time.Time
+func (m *mySQLUserRepository) FindPasswordResetByHash(codeHash string, ctx context.Context) (*domain.PasswordReset, error) {
+	query := "SELECT email, method, code_hash, attempts, expires_at, created_at FROM password_resets WHERE code_hash = ?"
+	return scanPasswordReset(m.db.QueryRow(query, codeHash))
+}
+
+// IncrementPasswordResetAttempts spends one guess. It reports false once the
+// code has used up maxAttempts, so concurrent guesses cannot exceed the limit.
+func (m *mySQLUserRepository) IncrementPasswordResetAttempts(email string, maxAttempts int, ctx context.Context) (bool, error) {
+	query := "UPDATE password_resets SET attempts = attempts + 1 WHERE email = ? AND attempts < ?"
+	res, err := m.db.Exec(query, email, maxAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// DeletePasswordReset removes the reset only if it still holds codeHash, so a
+// code that was replaced by a newer request cannot be consumed.
+func (m *mySQLUserRepository) DeletePasswordReset(email, codeHash string, ctx context.Context) (bool, error) {
+	query := "DELETE FROM password_resets WHERE email = ? AND code_hash = ?"
+	res, err := m.db.Exec(query, email, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func scanPasswordReset(row *sql.Row) (*domain.PasswordReset, error) {
+	var reset domain.PasswordReset
 
 	if err := row.Scan(
-		&otp,
-		&expires,
+		&reset.Email,
+		&reset.Method,
+		&reset.CodeHash,
+		&reset.Attempts,
+		&reset.ExpiresAt,
+		&reset.CreatedAt,
 	); err != nil {
-		return "", time.Time{}, err
+		return nil, err
 	}
 
-	return otp, expires, nil
+	return &reset, nil
 }
 
-func (m *mySQLUserRepository) DeleteOTP(email string, ctx context.Context) error {
-	query := "DELETE FROM password_resets WHERE email = ?"
-	_, err := m.db.Exec(query, email)
+const loginFailureColumns = "user_id, ip_address, failures, last_failed_at, locked_until"
+
+// GetLoginFailure returns an empty record when nothing has failed yet.
+func (m *mySQLUserRepository) GetLoginFailure(userId int, ipAddress string, ctx context.Context) (*domain.LoginFailure, error) {
+	query := "SELECT " + loginFailureColumns + " FROM login_failures WHERE user_id = ? AND ip_address = ?"
+	failure, err := scanLoginFailure(m.db.QueryRow(query, userId, ipAddress))
+	if errors.Is(err, sql.ErrNoRows) {
+		return &domain.LoginFailure{UserId: userId, IPAddress: ipAddress}, nil
+	}
+
+	return failure, err
+}
+
+// RecordLoginFailure counts one more failure. Failures older than windowStart
+// are forgotten, so the count starts again from one.
+func (m *mySQLUserRepository) RecordLoginFailure(userId int, ipAddress string, windowStart time.Time, ctx context.Context) (*domain.LoginFailure, error) {
+	query := `INSERT INTO login_failures (user_id, ip_address, failures, last_failed_at) VALUES (?, ?, 1, ?)
+		ON DUPLICATE KEY UPDATE failures = IF(last_failed_at < ?, 1, failures + 1), last_failed_at = VALUES(last_failed_at)`
+	if _, err := m.db.Exec(query, userId, ipAddress, time.Now(), windowStart); err != nil {
+		return nil, err
+	}
+
+	return m.GetLoginFailure(userId, ipAddress, ctx)
+}
+
+func (m *mySQLUserRepository) LockAccount(userId int, until time.Time, unlockTokenHash string, ctx context.Context) error {
+	query := `INSERT INTO login_failures (user_id, ip_address, failures, last_failed_at, locked_until, unlock_token_hash) VALUES (?, '', 0, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE failures = 0, locked_until = VALUES(locked_until), unlock_token_hash = VALUES(unlock_token_hash)`
+	_, err := m.db.Exec(query, userId, time.Now(), until, unlockTokenHash)
+	return err
+}
+
+func (m *mySQLUserRepository) FindLockByUnlockToken(unlockTokenHash string, ctx context.Context) (*domain.LoginFailure, error) {
+	query := "SELECT " + loginFailureColumns + " FROM login_failures WHERE ip_address = '' AND unlock_token_hash = ?"
+	return scanLoginFailure(m.db.QueryRow(query, unlockTokenHash))
+}
+
+// ResetLoginFailures clears the account-wide counter and the one for
+// ipAddress after a successful login. Backoff built up by other addresses
+// is left alone.
+func (m *mySQLUserRepository) ResetLoginFailures(userId int, ipAddress string, ctx context.Context) error {
+	_, err := m.db.Exec("DELETE FROM login_failures WHERE user_id = ? AND ip_address IN ('', ?)", userId, ipAddress)
+	return err
+}
+
+func (m *mySQLUserRepository) ClearLoginFailures(userId int, ctx context.Context) error {
+	_, err := m.db.Exec("DELETE FROM login_failures WHERE user_id = ?", userId)
 	return err
 }
+
+func scanLoginFailure(row rowScanner) (*domain.LoginFailure, error) {
+	var failure domain.LoginFailure
+	var lockedUntil sql.NullTime
+
+	if err := row.Scan(
+		&failure.UserId,
+		&failure.IPAddress,
+		&failure.Failures,
+		&failure.LastFailedAt,
+		&lockedUntil,
+	); err != nil {
+		return nil, err
+	}
+
+	if lockedUntil.Valid {
+		failure.LockedUntil = &lockedUntil.Time
+	}
+
+	return &failure, nil
+}
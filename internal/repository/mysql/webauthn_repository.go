@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+)
+
+type mySQLWebAuthnRepository struct {
+	db *sql.DB
+}
+
+func NewWebAuthnRepository(db *sql.DB) (domain.WebAuthnRepository, error) {
+	return &mySQLWebAuthnRepository{db: db}, nil
+}
+
+func (m *mySQLWebAuthnRepository) CreateCredential(credential *domain.WebAuthnCredential, ctx context.Context) error {
+	query := "INSERT INTO webauthn_credentials (id, user_id, name, data, sign_count) VALUES (?, ?, ?, ?, ?)"
+	_, err := m.db.Exec(query, credential.Id, credential.UserId, credential.Name, credential.Data, credential.SignCount)
+	return err
+}
+
+func (m *mySQLWebAuthnRepository) GetCredential(id string, ctx context.Context) (*domain.WebAuthnCredential, error) {
+	query := "SELECT id, user_id, name, data, sign_count, created_at, last_used_at FROM webauthn_credentials WHERE id = ?"
+	return scanWebAuthnCredential(m.db.QueryRow(query, id))
+}
+
+func (m *mySQLWebAuthnRepository) ListCredentialsByUser(userId int, ctx context.Context) ([]domain.WebAuthnCredential, error) {
+	query := "SELECT id, user_id, name, data, sign_count, created_at, last_used_at FROM webauthn_credentials WHERE user_id = ? ORDER BY created_at"
+	rows, err := m.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []domain.WebAuthnCredential{}
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, *credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+func (m *mySQLWebAuthnRepository) UpdateCredential(credential *domain.WebAuthnCredential, ctx context.Context) error {
+	query := "UPDATE webauthn_credentials SET data = ?, sign_count = ?, last_used_at = CURRENT_TIMESTAMP WHERE id = ?"
+	_, err := m.db.Exec(query, credential.Data, credential.SignCount, credential.Id)
+	return err
+}
+
+func (m *mySQLWebAuthnRepository) DeleteCredential(userId int, id string, ctx context.Context) (bool, error) {
+	res, err := m.db.Exec("DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (m *mySQLWebAuthnRepository) SaveCeremony(ceremony *domain.WebAuthnCeremony, ctx context.Context) error {
+	query := "INSERT INTO webauthn_ceremonies (token_hash, user_id, kind, data, expires_at) VALUES (?, ?, ?, ?, ?)"
+
+	var userId sql.NullInt64
+	if ceremony.UserId != 0 {
+		userId = sql.NullInt64{Int64: int64(ceremony.UserId), Valid: true}
+	}
+
+	_, err := m.db.Exec(query, ceremony.TokenHash, userId, ceremony.Kind, ceremony.Data, ceremony.ExpiresAt)
+	return err
+}
+
+// TakeCeremony returns the ceremony and deletes it, so each one can be
+// finished at most once even under concurrent requests.
+func (m *mySQLWebAuthnRepository) TakeCeremony(tokenHash string, ctx context.Context) (*domain.WebAuthnCeremony, error) {
+	query := "SELECT token_hash, user_id, kind, data, expires_at FROM webauthn_ceremonies WHERE token_hash = ?"
+	row := m.db.QueryRow(query, tokenHash)
+
+	var ceremony domain.WebAuthnCeremony
+	var userId sql.NullInt64
+
+	if err := row.Scan(
+		&ceremony.TokenHash,
+		&userId,
+		&ceremony.Kind,
+		&ceremony.Data,
+		&ceremony.ExpiresAt,
+	); err != nil {
+		return nil, err
+	}
+	ceremony.UserId = int(userId.Int64)
+
+	res, err := m.db.Exec("DELETE FROM webauthn_ceremonies WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected != 1 {
+		return nil, errors.New("ceremony already used")
+	}
+
+	return &ceremony, nil
+}
+
+func scanWebAuthnCredential(row rowScanner) (*domain.WebAuthnCredential, error) {
+	var credential domain.WebAuthnCredential
+	var lastUsedAt sql.NullTime
+
+	if err := row.Scan(
+		&credential.Id,
+		&credential.UserId,
+		&credential.Name,
+		&credential.Data,
+		&credential.SignCount,
+		&credential.CreatedAt,
+		&lastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		credential.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return &credential, nil
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+const (
+	emailChangeExpiry       = 24 * time.Hour
+	emailChangeCancelWindow = 7 * 24 * time.Hour
+	emailChangeCooldown     = time.Minute
+)
+
+type emailChangeUsecase struct {
+	emailChangeRepo domain.EmailChangeRepository
+	userRepo        domain.UserRepository
+	userUsecase     domain.UserUsecase
+	mailer          mailer.Mailer
+	hasher          utils.PasswordHasher
+	confirmURL      string
+	cancelURL       string
+	hideAccounts    bool
+}
+
+func NewEmailChangeUsecase(r domain.EmailChangeRepository, ur domain.UserRepository, u domain.UserUsecase, ml mailer.Mailer, hasher utils.PasswordHasher, confirmURL, cancelURL string, hideAccounts bool) domain.EmailChangeUsecase {
+	return &emailChangeUsecase{
+		emailChangeRepo: r,
+		userRepo:        ur,
+		userUsecase:     u,
+		mailer:          ml,
+		hasher:          hasher,
+		confirmURL:      confirmURL,
+		cancelURL:       cancelURL,
+		hideAccounts:    hideAccounts,
+	}
+}
+
+// Request starts a change to input.NewEmail. The account keeps its current
+// address until the link sent to the new one is opened, and the current
+// address is told about the request with a link to cancel it. With
+// enumeration protection on, an address that already has an account is
+// accepted like any other, but its owner gets a notice instead of a link.
+func (e *emailChangeUsecase) Request(userId int, input domain.ChangeEmailRequest, ctx context.Context) error {
+	user, err := e.userRepo.GetById(userId)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	// Wrong passwords count toward the login lockout, so this endpoint
+	// cannot be used to guess the password either.
+	if err := e.userUsecase.CheckLoginThrottle(userId, input.IPAddress, ctx); err != nil {
+		return err
+	}
+
+	if err := e.hasher.Compare(user.Password, input.Password); err != nil {
+		if err := e.userUsecase.RecordLoginFailure(userId, input.IPAddress, ctx); err != nil {
+			return err
+		}
+		return errors.New("wrong password")
+	}
+
+	if strings.EqualFold(user.Email, input.NewEmail) {
+		return errors.New("new email is the same as the current one")
+	}
+
+	owner := domain.User{Email: input.NewEmail}
+	taken := e.userRepo.GetByEmail(&owner, ctx) == nil
+	if taken && !e.hideAccounts {
+		return errors.New("email already registered")
+	}
+
+	if latest, err := e.emailChangeRepo.LatestByUser(userId, ctx); err == nil && latest.ConfirmedAt == nil && time.Since(latest.CreatedAt) < emailChangeCooldown {
+		return errors.New("please wait before requesting another email change")
+	}
+
+	if err := e.emailChangeRepo.DeletePendingByUser(userId, ctx); err != nil {
+		return err
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to generate token")
+	}
+
+	cancelToken, err := utils.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to generate token")
+	}
+
+	now := time.Now()
+	change := domain.EmailChange{
+		UserId:             userId,
+		OldEmail:           user.Email,
+		OldEmailVerifiedAt: user.EmailVerifiedAt,
+		NewEmail:           input.NewEmail,
+		TokenHash:          utils.HashToken(token),
+		CancelTokenHash:    utils.HashToken(cancelToken),
+		ExpiresAt:          now.Add(emailChangeExpiry),
+		CreatedAt:          now,
+	}
+
+	if err := e.emailChangeRepo.Create(&change, ctx); err != nil {
+		return fmt.Errorf("failed to create email change, error: %w", err)
+	}
+
+	var confirm *mailer.Message
+	if taken {
+		confirm, err = mailer.Render(mailer.TemplateEmailChangeTaken, change.NewEmail, map[string]any{
+			"Name":     owner.FullName,
+			"Username": owner.Username,
+		})
+	} else {
+		confirm, err = mailer.Render(mailer.TemplateEmailChangeConfirm, change.NewEmail, map[string]any{
+			"Name":      user.FullName,
+			"Link":      e.confirmURL + "?token=" + url.QueryEscape(token),
+			"ExpiresIn": humanDuration(emailChangeExpiry),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	notice, err := mailer.Render(mailer.TemplateEmailChangeNotice, change.OldEmail, map[string]any{
+		"Name":       user.FullName,
+		"NewEmail":   change.NewEmail,
+		"CancelLink": e.cancelURL + "?token=" + url.QueryEscape(cancelToken),
+		"ExpiresIn":  humanDuration(emailChangeCancelWindow),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := e.mailer.Send(confirm, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	if err := e.mailer.Send(notice, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+func (e *emailChangeUsecase) Confirm(input domain.EmailChangeTokenRequest, ctx context.Context) error {
+	change, err := e.emailChangeRepo.GetByTokenHash(utils.HashToken(input.Token), ctx)
+	if err != nil || change.ConfirmedAt != nil || time.Now().After(change.ExpiresAt) {
+		return errors.New("invalid or expired email change link")
+	}
+
+	if err := e.checkAvailable(change.NewEmail, ctx); err != nil {
+		return err
+	}
+
+	confirmed, err := e.emailChangeRepo.MarkConfirmed(change.Id, ctx)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errors.New("invalid or expired email change link")
+	}
+
+	// The unique index on users.email still guards against another account
+	// taking the address between the check above and this update.
+	updated, err := e.userRepo.UpdateEmail(change.UserId, change.OldEmail, change.NewEmail, ctx)
+	if err != nil {
+		e.emailChangeRepo.Delete(change.Id, ctx)
+		return fmt.Errorf("failed to change email, error: %w", err)
+	}
+	if !updated {
+		e.emailChangeRepo.Delete(change.Id, ctx)
+		return errors.New("invalid or expired email change link")
+	}
+
+	return nil
+}
+
+// Cancel drops a pending change. If the change was already confirmed, the
+// old address is restored as it was, verified or not. Whoever made the
+// change knew the password, so the password is cleared, which also revokes
+// every session, and a reset is sent to the restored address.
+func (e *emailChangeUsecase) Cancel(input domain.EmailChangeTokenRequest, ctx context.Context) error {
+	change, err := e.emailChangeRepo.GetByCancelTokenHash(utils.HashToken(input.Token), ctx)
+	if err != nil || time.Since(change.CreatedAt) > emailChangeCancelWindow {
+		return errors.New("invalid or expired cancel link")
+	}
+
+	deleted, err := e.emailChangeRepo.Delete(change.Id, ctx)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("invalid or expired cancel link")
+	}
+
+	if change.ConfirmedAt == nil {
+		return nil
+	}
+
+	restored, err := e.userRepo.RestoreEmail(change.UserId, change.NewEmail, change.OldEmail, change.OldEmailVerifiedAt, ctx)
+	if err != nil {
+		return fmt.Errorf("failed to restore email, error: %w", err)
+	}
+	if !restored {
+		return errors.New("the email address has changed again and cannot be restored")
+	}
+
+	// No hasher accepts an empty hash, so the account cannot be logged in
+	// to until the password is reset.
+	if err := e.userRepo.UpdatePassword(change.UserId, "", ctx); err != nil {
+		return fmt.Errorf("failed to clear password, error: %w", err)
+	}
+
+	if err := e.userUsecase.RevokeAllSessions(change.UserId, ctx); err != nil {
+		return err
+	}
+
+	// A failed send is not fatal; the owner can ask for another reset.
+	e.userUsecase.ForgotPassword(domain.ForgotPasswordRequest{Email: change.OldEmail}, ctx)
+
+	return nil
+}
+
+func (e *emailChangeUsecase) checkAvailable(email string, ctx context.Context) error {
+	var existing domain.User
+	existing.Email = email
+
+	if err := e.userRepo.GetByEmail(&existing, ctx); err == nil {
+		return errors.New("email already registered")
+	}
+
+	return nil
+}
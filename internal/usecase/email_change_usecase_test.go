@@ -0,0 +1,234 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+)
+
+type memoryEmailChangeRepository struct {
+	changes map[int]*domain.EmailChange
+	nextId  int
+}
+
+func newMemoryEmailChangeRepository() *memoryEmailChangeRepository {
+	return &memoryEmailChangeRepository{changes: map[int]*domain.EmailChange{}}
+}
+
+func (r *memoryEmailChangeRepository) Create(change *domain.EmailChange, ctx context.Context) error {
+	r.nextId++
+	change.Id = r.nextId
+
+	stored := *change
+	r.changes[change.Id] = &stored
+	return nil
+}
+
+func (r *memoryEmailChangeRepository) find(match func(*domain.EmailChange) bool) (*domain.EmailChange, error) {
+	var latest *domain.EmailChange
+	for _, stored := range r.changes {
+		if match(stored) && (latest == nil || stored.Id > latest.Id) {
+			latest = stored
+		}
+	}
+	if latest == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	change := *latest
+	return &change, nil
+}
+
+func (r *memoryEmailChangeRepository) GetByTokenHash(tokenHash string, ctx context.Context) (*domain.EmailChange, error) {
+	return r.find(func(c *domain.EmailChange) bool { return c.TokenHash == tokenHash })
+}
+
+func (r *memoryEmailChangeRepository) GetByCancelTokenHash(cancelTokenHash string, ctx context.Context) (*domain.EmailChange, error) {
+	return r.find(func(c *domain.EmailChange) bool { return c.CancelTokenHash == cancelTokenHash })
+}
+
+func (r *memoryEmailChangeRepository) LatestByUser(userId int, ctx context.Context) (*domain.EmailChange, error) {
+	return r.find(func(c *domain.EmailChange) bool { return c.UserId == userId })
+}
+
+func (r *memoryEmailChangeRepository) MarkConfirmed(id int, ctx context.Context) (bool, error) {
+	stored, found := r.changes[id]
+	if !found || stored.ConfirmedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	stored.ConfirmedAt = &now
+	return true, nil
+}
+
+func (r *memoryEmailChangeRepository) Delete(id int, ctx context.Context) (bool, error) {
+	if _, found := r.changes[id]; !found {
+		return false, nil
+	}
+
+	delete(r.changes, id)
+	return true, nil
+}
+
+func (r *memoryEmailChangeRepository) DeletePendingByUser(userId int, ctx context.Context) error {
+	for id, stored := range r.changes {
+		if stored.UserId == userId && stored.ConfirmedAt == nil {
+			delete(r.changes, id)
+		}
+	}
+
+	return nil
+}
+
+func (r *stubUserRepository) UpdateEmail(userId int, oldEmail, newEmail string, ctx context.Context) (bool, error) {
+	user, found := r.users[userId]
+	if !found || user.Email != oldEmail {
+		return false, nil
+	}
+
+	now := time.Now()
+	user.Email = newEmail
+	user.EmailVerifiedAt = &now
+	return true, nil
+}
+
+func (r *stubUserRepository) RestoreEmail(userId int, currentEmail, email string, verifiedAt *time.Time, ctx context.Context) (bool, error) {
+	user, found := r.users[userId]
+	if !found || user.Email != currentEmail {
+		return false, nil
+	}
+
+	user.Email = email
+	user.EmailVerifiedAt = verifiedAt
+	return true, nil
+}
+
+func (r *stubUserRepository) UpdatePassword(userId int, password string, ctx context.Context) error {
+	r.users[userId].Password = password
+	return nil
+}
+
+func (r *stubUserRepository) FindPasswordReset(email string, ctx context.Context) (*domain.PasswordReset, error) {
+	reset, found := r.resets[email]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	return &reset, nil
+}
+
+func (r *stubUserRepository) SavePasswordReset(reset *domain.PasswordReset, ctx context.Context) error {
+	if r.resets == nil {
+		r.resets = map[string]domain.PasswordReset{}
+	}
+
+	r.resets[reset.Email] = *reset
+	return nil
+}
+
+var tokenParam = regexp.MustCompile(`token=([^"&\s]+)`)
+
+// linkToken pulls the token out of the link in the last message sent to.
+func linkToken(t *testing.T, mail *mailer.MemoryMailer, to string) string {
+	t.Helper()
+
+	messages := mail.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+
+		match := tokenParam.FindStringSubmatch(messages[i].Text)
+		if match == nil {
+			t.Fatalf("no link in message to %s: %q", to, messages[i].Text)
+		}
+
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	t.Fatalf("no message sent to %s", to)
+	return ""
+}
+
+func TestEmailChangeRequestFailuresLockAccount(t *testing.T) {
+	const maxFailures = 3
+	f := newMFAFixture(t, maxFailures)
+	changes := NewEmailChangeUsecase(nil, f.users, f.logins, f.mail, f.logins.hasher, "", "", false)
+
+	for i := 1; i <= maxFailures; i++ {
+		err := changes.Request(1, domain.ChangeEmailRequest{
+			NewEmail:  "new@example.com",
+			Password:  "not the password",
+			IPAddress: mfaTestIP,
+		}, context.Background())
+		if i < maxFailures && (err == nil || errors.Is(err, domain.ErrAccountLocked)) {
+			t.Fatalf("attempt %d: err = %v, want a wrong password", i, err)
+		}
+		if i == maxFailures && !errors.Is(err, domain.ErrAccountLocked) {
+			t.Fatalf("attempt %d: err = %v, want ErrAccountLocked", i, err)
+		}
+	}
+
+	err := changes.Request(1, domain.ChangeEmailRequest{
+		NewEmail:  "new@example.com",
+		Password:  mfaTestPassword,
+		IPAddress: mfaTestIP,
+	}, context.Background())
+	if !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestEmailChangeCancelRestoresAddressAndForcesReset(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	f.logins.passwordReset = PasswordResetConfig{Secret: []byte("test secret")}
+	changes := NewEmailChangeUsecase(newMemoryEmailChangeRepository(), f.users, f.logins, f.mail, f.logins.hasher, "http://localhost/confirm", "http://localhost/cancel", false)
+	ctx := context.Background()
+
+	// The old address was never verified, and must not come back verified.
+	user := f.users.users[1]
+	user.EmailVerifiedAt = nil
+
+	if err := changes.Request(1, domain.ChangeEmailRequest{NewEmail: "new@example.com", Password: mfaTestPassword, IPAddress: mfaTestIP}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := changes.Confirm(domain.EmailChangeTokenRequest{Token: linkToken(t, f.mail, "new@example.com")}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "new@example.com" || user.EmailVerifiedAt == nil {
+		t.Fatalf("after confirm: email %q, verified at %v", user.Email, user.EmailVerifiedAt)
+	}
+
+	f.logins.StartSession(&domain.Session{UserId: 1}, ctx)
+
+	if err := changes.Cancel(domain.EmailChangeTokenRequest{Token: linkToken(t, f.mail, "test@example.com")}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "test@example.com" || user.EmailVerifiedAt != nil {
+		t.Fatalf("after cancel: email %q, verified at %v, want the unverified old address", user.Email, user.EmailVerifiedAt)
+	}
+	if len(f.logins.sessionRepo.(*stubSessionRepository).sessions) != 0 {
+		t.Fatal("sessions survived the cancel")
+	}
+	if _, err := f.users.FindPasswordReset("test@example.com", ctx); err != nil {
+		t.Fatal("no password reset sent to the restored address")
+	}
+
+	_, err := f.logins.Login(domain.LoginRequest{Email: "test@example.com", Password: mfaTestPassword, IPAddress: mfaTestIP}, ctx)
+	if err == nil {
+		t.Fatal("the old password still logs in after the cancel")
+	}
+}
@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+const (
+	emailVerificationExpiry   = 24 * time.Hour
+	emailVerificationCooldown = time.Minute
+)
+
+type emailVerificationUsecase struct {
+	verificationRepo domain.EmailVerificationRepository
+	userRepo         domain.UserRepository
+	mailer           mailer.Mailer
+	policy           string
+	linkURL          string
+	hideAccounts     bool
+}
+
+func NewEmailVerificationUsecase(r domain.EmailVerificationRepository, ur domain.UserRepository, ml mailer.Mailer, policy, linkURL string, hideAccounts bool) domain.EmailVerificationUsecase {
+	return &emailVerificationUsecase{
+		verificationRepo: r,
+		userRepo:         ur,
+		mailer:           ml,
+		policy:           policy,
+		linkURL:          linkURL,
+		hideAccounts:     hideAccounts,
+	}
+}
+
+// Send emails a fresh verification link for the user's current address.
+// Links sent earlier stop working.
+func (e *emailVerificationUsecase) Send(user *domain.User, ctx context.Context) error {
+	if err := e.verificationRepo.DeleteByUser(user.Id, ctx); err != nil {
+		return err
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to generate token")
+	}
+
+	now := time.Now()
+	verification := domain.EmailVerification{
+		UserId:    user.Id,
+		Email:     user.Email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(emailVerificationExpiry),
+		CreatedAt: now,
+	}
+
+	if err := e.verificationRepo.Create(&verification, ctx); err != nil {
+		return fmt.Errorf("failed to create verification, error: %w", err)
+	}
+
+	msg, err := mailer.Render(mailer.TemplateEmailVerification, user.Email, map[string]any{
+		"Name":      user.FullName,
+		"Link":      e.linkURL + "?token=" + url.QueryEscape(token),
+		"ExpiresIn": humanDuration(emailVerificationExpiry),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := e.mailer.Send(msg, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+func (e *emailVerificationUsecase) Resend(input domain.ResendVerificationRequest, ctx context.Context) error {
+	var user domain.User
+	user.Email = input.Email
+
+	if err := e.userRepo.GetByEmail(&user, ctx); err != nil {
+		return hideAccountError(e.hideAccounts, errors.New("user not found"))
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return hideAccountError(e.hideAccounts, errors.New("email already verified"))
+	}
+
+	if latest, err := e.verificationRepo.LatestByUser(user.Id, ctx); err == nil && time.Since(latest.CreatedAt) < emailVerificationCooldown {
+		return hideAccountError(e.hideAccounts, errors.New("please wait before requesting another email"))
+	}
+
+	return e.Send(&user, ctx)
+}
+
+func (e *emailVerificationUsecase) Confirm(input domain.VerifyEmailRequest, ctx context.Context) error {
+	verification, err := e.verificationRepo.GetByTokenHash(utils.HashToken(input.Token), ctx)
+	if err != nil || time.Now().After(verification.ExpiresAt) {
+		return errors.New("invalid or expired verification link")
+	}
+
+	deleted, err := e.verificationRepo.Delete(verification.Id, ctx)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("invalid or expired verification link")
+	}
+
+	verified, err := e.userRepo.MarkEmailVerified(verification.UserId, verification.Email, ctx)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errors.New("invalid or expired verification link")
+	}
+
+	return nil
+}
+
+func (e *emailVerificationUsecase) CheckLogin(user *domain.User) error {
+	if e.policy == domain.EmailVerificationBlockLogin && user.EmailVerifiedAt == nil {
+		return errors.New("email address is not verified")
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+const (
+	loginFailureWindow = time.Hour
+	loginBackoffAfter  = 3
+	loginBackoffMax    = 5 * time.Minute
+)
+
+// LockoutConfig controls when repeated failed logins lock an account.
+// MaxFailures counts failures from every address within an hour; zero
+// turns the lock off.
+type LockoutConfig struct {
+	MaxFailures int
+	Duration    time.Duration
+	UnlockURL   string
+}
+
+// checkLoginThrottle runs before the password is compared, so a locked
+// account or a backed-off address cannot be used to keep guessing.
+func (u *userUsecase) checkLoginThrottle(userId int, ipAddress string, ctx context.Context) error {
+	account, err := u.userRepo.GetLoginFailure(userId, "", ctx)
+	if err != nil {
+		return err
+	}
+
+	if account.LockedUntil != nil && time.Now().Before(*account.LockedUntil) {
+		return domain.ErrAccountLocked
+	}
+
+	perAddress, err := u.userRepo.GetLoginFailure(userId, ipAddress, ctx)
+	if err != nil {
+		return err
+	}
+
+	if wait := loginBackoff(perAddress.Failures) - time.Since(perAddress.LastFailedAt); wait > 0 {
+		return fmt.Errorf("%w, try again in %s", domain.ErrLoginThrottled, humanDuration(wait))
+	}
+
+	return nil
+}
+
+// hideLoginThrottle reports a lockout or backoff as a wrong password when
+// enumeration protection is on. Only existing accounts are ever locked or
+// backed off, so the distinct errors would confirm the email is registered.
+// The limits still apply; the caller just cannot tell why it failed.
+func (u *userUsecase) hideLoginThrottle(err error) error {
+	if u.hideAccounts && (errors.Is(err, domain.ErrAccountLocked) || errors.Is(err, domain.ErrLoginThrottled)) {
+		return errors.New("wrong email or password")
+	}
+
+	return err
+}
+
+// loginBackoff doubles the wait for every failure past loginBackoffAfter.
+func loginBackoff(failures int) time.Duration {
+	if failures < loginBackoffAfter {
+		return 0
+	}
+
+	wait := time.Second
+	for i := loginBackoffAfter; i < failures && wait < loginBackoffMax; i++ {
+		wait *= 2
+	}
+
+	return min(wait, loginBackoffMax)
+}
+
+// CheckLoginThrottle applies the lockout and backoff to a check made after
+// the password, such as a second factor.
+func (u *userUsecase) CheckLoginThrottle(userId int, ipAddress string, ctx context.Context) error {
+	return u.checkLoginThrottle(userId, ipAddress, ctx)
+}
+
+// RecordLoginFailure counts a failed check made after the password like a
+// wrong password, so it adds to the same backoff and lockout.
+func (u *userUsecase) RecordLoginFailure(userId int, ipAddress string, ctx context.Context) error {
+	user, err := u.userRepo.GetById(userId)
+	if err != nil {
+		return errors.New("user not found")
+	}
+
+	return u.recordLoginFailure(user, ipAddress, ctx)
+}
+
+func (u *userUsecase) recordLoginFailure(user *domain.User, ipAddress string, ctx context.Context) error {
+	windowStart := time.Now().Add(-loginFailureWindow)
+
+	if _, err := u.userRepo.RecordLoginFailure(user.Id, ipAddress, windowStart, ctx); err != nil {
+		return err
+	}
+
+	account, err := u.userRepo.RecordLoginFailure(user.Id, "", windowStart, ctx)
+	if err != nil {
+		return err
+	}
+
+	if u.lockout.MaxFailures <= 0 || account.Failures < u.lockout.MaxFailures {
+		return nil
+	}
+
+	return u.lockAccount(user, ctx)
+}
+
+// lockAccount locks the account for the configured duration and emails the
+// owner a link that lifts the lock early.
+func (u *userUsecase) lockAccount(user *domain.User, ctx context.Context) error {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to generate token")
+	}
+
+	if err := u.userRepo.LockAccount(user.Id, time.Now().Add(u.lockout.Duration), utils.HashToken(token), ctx); err != nil {
+		return fmt.Errorf("failed to lock account, error: %w", err)
+	}
+
+	msg, err := mailer.Render(mailer.TemplateAccountLocked, user.Email, map[string]any{
+		"Name":      user.FullName,
+		"Link":      u.lockout.UnlockURL + "?token=" + url.QueryEscape(token),
+		"LockedFor": humanDuration(u.lockout.Duration),
+	})
+	if err == nil {
+		u.mailer.Send(msg, ctx)
+	}
+
+	return domain.ErrAccountLocked
+}
+
+func (u *userUsecase) UnlockAccount(input domain.UnlockAccountRequest, ctx context.Context) error {
+	lock, err := u.userRepo.FindLockByUnlockToken(utils.HashToken(input.Token), ctx)
+	if err != nil || lock.LockedUntil == nil || time.Now().After(*lock.LockedUntil) {
+		return errors.New("invalid or expired unlock link")
+	}
+
+	return u.userRepo.ClearLoginFailures(lock.UserId, ctx)
+}
+
+// UnlockUser lifts a lock and forgets all failed logins, for operators.
+func (u *userUsecase) UnlockUser(userId int, ctx context.Context) error {
+	if _, err := u.userRepo.GetById(userId); err != nil {
+		return errors.New("user not found")
+	}
+
+	return u.userRepo.ClearLoginFailures(userId, ctx)
+}
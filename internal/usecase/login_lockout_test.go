@@ -0,0 +1,313 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+)
+
+// The login failure methods of stubUserRepository keep the rows of the
+// login_failures table in memory, keyed the same way.
+
+type loginFailureKey struct {
+	userId    int
+	ipAddress string
+}
+
+type storedLoginFailure struct {
+	domain.LoginFailure
+	unlockTokenHash string
+}
+
+func (r *stubUserRepository) GetLoginFailure(userId int, ipAddress string, ctx context.Context) (*domain.LoginFailure, error) {
+	stored, found := r.failures[loginFailureKey{userId, ipAddress}]
+	if !found {
+		return &domain.LoginFailure{UserId: userId, IPAddress: ipAddress}, nil
+	}
+
+	failure := stored.LoginFailure
+	return &failure, nil
+}
+
+func (r *stubUserRepository) row(userId int, ipAddress string) *storedLoginFailure {
+	if r.failures == nil {
+		r.failures = map[loginFailureKey]*storedLoginFailure{}
+	}
+
+	key := loginFailureKey{userId, ipAddress}
+	stored, found := r.failures[key]
+	if !found {
+		stored = &storedLoginFailure{LoginFailure: domain.LoginFailure{UserId: userId, IPAddress: ipAddress}}
+		r.failures[key] = stored
+	}
+
+	return stored
+}
+
+func (r *stubUserRepository) RecordLoginFailure(userId int, ipAddress string, windowStart time.Time, ctx context.Context) (*domain.LoginFailure, error) {
+	stored := r.row(userId, ipAddress)
+	if stored.LastFailedAt.Before(windowStart) {
+		stored.Failures = 0
+	}
+	stored.Failures++
+	stored.LastFailedAt = time.Now()
+
+	return r.GetLoginFailure(userId, ipAddress, ctx)
+}
+
+func (r *stubUserRepository) LockAccount(userId int, until time.Time, unlockTokenHash string, ctx context.Context) error {
+	stored := r.row(userId, "")
+	stored.Failures = 0
+	stored.LockedUntil = &until
+	stored.unlockTokenHash = unlockTokenHash
+	return nil
+}
+
+func (r *stubUserRepository) FindLockByUnlockToken(unlockTokenHash string, ctx context.Context) (*domain.LoginFailure, error) {
+	for key, stored := range r.failures {
+		if key.ipAddress == "" && stored.unlockTokenHash == unlockTokenHash {
+			failure := stored.LoginFailure
+			return &failure, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func (r *stubUserRepository) ResetLoginFailures(userId int, ipAddress string, ctx context.Context) error {
+	delete(r.failures, loginFailureKey{userId, ""})
+	delete(r.failures, loginFailureKey{userId, ipAddress})
+	return nil
+}
+
+func (r *stubUserRepository) ClearLoginFailures(userId int, ctx context.Context) error {
+	for key := range r.failures {
+		if key.userId == userId {
+			delete(r.failures, key)
+		}
+	}
+
+	return nil
+}
+
+func TestChangePasswordFailuresLockAccount(t *testing.T) {
+	const maxFailures = 3
+	logins := newMFAFixture(t, maxFailures).logins
+
+	for i := 1; i <= maxFailures; i++ {
+		_, _, err := logins.ChangePassword(1, domain.ChangePasswordRequest{
+			CurrentPassword: "not the password",
+			NewPassword:     "another long passphrase",
+			IPAddress:       mfaTestIP,
+		}, context.Background())
+		if i < maxFailures && (err == nil || errors.Is(err, domain.ErrAccountLocked)) {
+			t.Fatalf("attempt %d: err = %v, want a wrong password", i, err)
+		}
+		if i == maxFailures && !errors.Is(err, domain.ErrAccountLocked) {
+			t.Fatalf("attempt %d: err = %v, want ErrAccountLocked", i, err)
+		}
+	}
+
+	// The right password does not get past the lock either.
+	_, _, err := logins.ChangePassword(1, domain.ChangePasswordRequest{
+		CurrentPassword: mfaTestPassword,
+		NewPassword:     "another long passphrase",
+		IPAddress:       mfaTestIP,
+	}, context.Background())
+	if !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{loginBackoffAfter - 1, 0},
+		{loginBackoffAfter, time.Second},
+		{loginBackoffAfter + 1, 2 * time.Second},
+		{loginBackoffAfter + 4, 16 * time.Second},
+		{loginBackoffAfter + 8, 256 * time.Second},
+		{loginBackoffAfter + 9, loginBackoffMax},
+		{1000, loginBackoffMax},
+	} {
+		if got := loginBackoff(tt.failures); got != tt.want {
+			t.Errorf("loginBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// newLockoutFixture returns the login usecase of an MFA fixture; the
+// lockout code only needs its user repository and mailer.
+func newLockoutFixture(t *testing.T, maxFailures int) (*userUsecase, *stubUserRepository, *mailer.MemoryMailer) {
+	t.Helper()
+
+	f := newMFAFixture(t, maxFailures)
+	return f.logins, f.users, f.mail
+}
+
+func TestRecordLoginFailure(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		maxFailures int
+		earlier     int           // failures already counted
+		age         time.Duration // since the last of them
+		wantCount   int
+		wantLocked  bool
+	}{
+		{name: "first failure", maxFailures: 3, wantCount: 1},
+		{name: "below threshold", maxFailures: 3, earlier: 1, age: time.Minute, wantCount: 2},
+		{name: "reaches threshold", maxFailures: 3, earlier: 2, age: time.Minute, wantLocked: true},
+		{name: "window expired", maxFailures: 3, earlier: 2, age: loginFailureWindow + time.Minute, wantCount: 1},
+		{name: "lock disabled", maxFailures: 0, earlier: 50, age: time.Minute, wantCount: 51},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logins, users, mail := newLockoutFixture(t, tt.maxFailures)
+			ctx := context.Background()
+
+			if tt.earlier > 0 {
+				for _, ip := range []string{"", mfaTestIP} {
+					row := users.row(1, ip)
+					row.Failures = tt.earlier
+					row.LastFailedAt = time.Now().Add(-tt.age)
+				}
+			}
+
+			err := logins.RecordLoginFailure(1, mfaTestIP, ctx)
+			if locked := errors.Is(err, domain.ErrAccountLocked); locked != tt.wantLocked || (!locked && err != nil) {
+				t.Fatalf("err = %v, want locked %v", err, tt.wantLocked)
+			}
+
+			account, _ := users.GetLoginFailure(1, "", ctx)
+			perAddress, _ := users.GetLoginFailure(1, mfaTestIP, ctx)
+
+			if tt.wantLocked {
+				if account.LockedUntil == nil || time.Until(*account.LockedUntil) < 14*time.Minute {
+					t.Fatalf("locked until %v, want about 15 minutes from now", account.LockedUntil)
+				}
+				if len(mail.Messages()) != 1 {
+					t.Fatalf("%d messages sent, want the lock notice", len(mail.Messages()))
+				}
+				return
+			}
+
+			if account.Failures != tt.wantCount || perAddress.Failures != tt.wantCount {
+				t.Fatalf("failures: account %d, address %d, want %d", account.Failures, perAddress.Failures, tt.wantCount)
+			}
+			if account.LockedUntil != nil || len(mail.Messages()) != 0 {
+				t.Fatal("account locked below the threshold")
+			}
+		})
+	}
+}
+
+func TestCheckLoginThrottle(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	for _, tt := range []struct {
+		name        string
+		lockedUntil *time.Time
+		failures    int
+		age         time.Duration
+		ipAddress   string
+		want        error
+	}{
+		{name: "clean", ipAddress: mfaTestIP},
+		{name: "locked", lockedUntil: &future, ipAddress: mfaTestIP, want: domain.ErrAccountLocked},
+		{name: "lock expired", lockedUntil: &past, ipAddress: mfaTestIP},
+		{name: "backing off", failures: loginBackoffAfter + 1, ipAddress: mfaTestIP, want: domain.ErrLoginThrottled},
+		{name: "backoff over", failures: loginBackoffAfter + 1, age: time.Minute, ipAddress: mfaTestIP},
+		{name: "other address", failures: loginBackoffAfter + 1, ipAddress: "198.51.100.1"},
+		{name: "locked from other address", lockedUntil: &future, ipAddress: "198.51.100.1", want: domain.ErrAccountLocked},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logins, users, _ := newLockoutFixture(t, 10)
+			ctx := context.Background()
+
+			users.row(1, "").LockedUntil = tt.lockedUntil
+			row := users.row(1, mfaTestIP)
+			row.Failures = tt.failures
+			row.LastFailedAt = time.Now().Add(-tt.age)
+
+			err := logins.CheckLoginThrottle(1, tt.ipAddress, ctx)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		token   string
+		expired bool
+		wantErr bool
+	}{
+		{name: "valid link"},
+		{name: "wrong token", token: "not the token", wantErr: true},
+		{name: "lock already over", expired: true, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			logins, users, mail := newLockoutFixture(t, 1)
+			ctx := context.Background()
+
+			if err := logins.RecordLoginFailure(1, mfaTestIP, ctx); !errors.Is(err, domain.ErrAccountLocked) {
+				t.Fatalf("err = %v, want ErrAccountLocked", err)
+			}
+
+			token := tt.token
+			if token == "" {
+				token = linkToken(t, mail, "test@example.com")
+			}
+			if tt.expired {
+				past := time.Now().Add(-time.Second)
+				users.row(1, "").LockedUntil = &past
+			}
+
+			err := logins.UnlockAccount(domain.UnlockAccountRequest{Token: token}, ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr {
+				if err := logins.CheckLoginThrottle(1, mfaTestIP, ctx); err != nil {
+					t.Fatalf("still throttled after unlock: %v", err)
+				}
+				if err := logins.UnlockAccount(domain.UnlockAccountRequest{Token: token}, ctx); err == nil {
+					t.Fatal("unlock link worked twice")
+				}
+			}
+		})
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	logins, users, _ := newLockoutFixture(t, 1)
+	ctx := context.Background()
+
+	logins.RecordLoginFailure(1, mfaTestIP, ctx)
+	users.row(1, "198.51.100.1").Failures = loginBackoffAfter + 5
+	users.row(1, "198.51.100.1").LastFailedAt = time.Now()
+
+	if err := logins.UnlockUser(1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, ip := range []string{mfaTestIP, "198.51.100.1"} {
+		if err := logins.CheckLoginThrottle(1, ip, ctx); err != nil {
+			t.Fatalf("%s still throttled after UnlockUser: %v", ip, err)
+		}
+	}
+
+	if err := logins.UnlockUser(42, ctx); err == nil {
+		t.Fatal("UnlockUser accepted an unknown user")
+	}
+}
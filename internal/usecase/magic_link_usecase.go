@@ -0,0 +1,153 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+const (
+	magicLinkExpiry      = 15 * time.Minute
+	magicLinkCooldown    = time.Minute
+	magicLinkHourlyLimit = 5
+)
+
+type magicLinkUsecase struct {
+	magicLinkRepo domain.MagicLinkRepository
+	userRepo      domain.UserRepository
+	userUsecase   domain.UserUsecase
+	mailer        mailer.Mailer
+	signingKey    []byte
+	linkURL       string
+	hideAccounts  bool
+}
+
+func NewMagicLinkUsecase(r domain.MagicLinkRepository, ur domain.UserRepository, u domain.UserUsecase, ml mailer.Mailer, signingKey []byte, linkURL string, hideAccounts bool) domain.MagicLinkUsecase {
+	return &magicLinkUsecase{
+		magicLinkRepo: r,
+		userRepo:      ur,
+		userUsecase:   u,
+		mailer:        ml,
+		signingKey:    signingKey,
+		linkURL:       linkURL,
+		hideAccounts:  hideAccounts,
+	}
+}
+
+func (m *magicLinkUsecase) Send(input domain.MagicLinkRequest, ctx context.Context) error {
+	var user domain.User
+	user.Email = input.Email
+
+	if err := m.userRepo.GetByEmail(&user, ctx); err != nil {
+		return hideAccountError(m.hideAccounts, errors.New("user not found"))
+	}
+
+	if err := m.checkThrottle(input.Email, ctx); err != nil {
+		return hideAccountError(m.hideAccounts, err)
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return errors.New("failed to generate token")
+	}
+
+	now := time.Now()
+	link := domain.MagicLink{
+		Email:     input.Email,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(magicLinkExpiry),
+		CreatedAt: now,
+	}
+
+	if err := m.magicLinkRepo.Create(&link, ctx); err != nil {
+		return fmt.Errorf("failed to create magic link, error: %w", err)
+	}
+
+	msg, err := mailer.Render(mailer.TemplateMagicLink, input.Email, map[string]any{
+		"Name":      user.FullName,
+		"Link":      m.linkURL + "?token=" + url.QueryEscape(utils.SignValue(m.signingKey, token)),
+		"ExpiresIn": "15 minutes",
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := m.mailer.Send(msg, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+func (m *magicLinkUsecase) Login(input domain.MagicLinkLoginRequest, ctx context.Context) (*domain.LoginResult, error) {
+	token, ok := utils.VerifySignedValue(m.signingKey, input.Token)
+	if !ok {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	link, err := m.magicLinkRepo.GetByTokenHash(utils.HashToken(token), ctx)
+	if err != nil || link.UsedAt != nil || time.Now().After(link.ExpiresAt) {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	used, err := m.magicLinkRepo.MarkUsed(link.Id, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	var user domain.User
+	user.Email = link.Email
+
+	if err := m.userRepo.GetByEmail(&user, ctx); err != nil {
+		return nil, errors.New("invalid or expired link")
+	}
+
+	// Following the link proves the user controls the address.
+	if user.EmailVerifiedAt == nil {
+		if verified, err := m.userRepo.MarkEmailVerified(user.Id, user.Email, ctx); err == nil && verified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	session := domain.Session{
+		UserId:    user.Id,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	return m.userUsecase.CompleteLogin(&user, &session, ctx)
+}
+
+// checkThrottle allows one link per email per minute and a handful per hour,
+// so the endpoint cannot be used to flood someone's inbox.
+func (m *magicLinkUsecase) checkThrottle(email string, ctx context.Context) error {
+	now := time.Now()
+
+	recent, err := m.magicLinkRepo.CountSince(email, now.Add(-magicLinkCooldown), ctx)
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return errors.New("please wait before requesting another link")
+	}
+
+	hourly, err := m.magicLinkRepo.CountSince(email, now.Add(-time.Hour), ctx)
+	if err != nil {
+		return err
+	}
+	if hourly >= magicLinkHourlyLimit {
+		return errors.New("too many login links requested, try again later")
+	}
+
+	return nil
+}
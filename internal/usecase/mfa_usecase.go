@@ -0,0 +1,303 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/totp"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	mfaMaxAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type mfaUsecase struct {
+	mfaRepo       domain.MFARepository
+	userUsecase   domain.UserUsecase
+	webauthn      domain.WebAuthnUsecase
+	encryptionKey []byte
+	issuer        string
+}
+
+func NewMFAUsecase(r domain.MFARepository, u domain.UserUsecase, w domain.WebAuthnUsecase, encryptionKey []byte, issuer string) domain.MFAUsecase {
+	return &mfaUsecase{
+		mfaRepo:       r,
+		userUsecase:   u,
+		webauthn:      w,
+		encryptionKey: encryptionKey,
+		issuer:        issuer,
+	}
+}
+
+func (m *mfaUsecase) EnrollTOTP(userId int, ctx context.Context) (*domain.TOTPEnrollResponse, error) {
+	if enrollment, err := m.mfaRepo.GetTOTP(userId, ctx); err == nil && enrollment.ConfirmedAt != nil {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	user, err := m.userUsecase.GetProfile(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errors.New("failed to generate secret")
+	}
+
+	encrypted, err := utils.EncryptString(m.encryptionKey, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret, error: %w", err)
+	}
+
+	enrollment := domain.TOTPEnrollment{
+		UserId:          userId,
+		SecretEncrypted: encrypted,
+	}
+	if err := m.mfaRepo.SaveTOTP(&enrollment, ctx); err != nil {
+		return nil, fmt.Errorf("failed to save totp secret, error: %w", err)
+	}
+
+	uri := totp.URI(m.issuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render qr code, error: %w", err)
+	}
+
+	return &domain.TOTPEnrollResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func (m *mfaUsecase) ConfirmTOTP(userId int, input domain.TOTPCodeRequest, ctx context.Context) ([]string, error) {
+	enrollment, err := m.mfaRepo.GetTOTP(userId, ctx)
+	if err != nil {
+		return nil, errors.New("two-factor authentication is not enrolled")
+	}
+
+	if enrollment.ConfirmedAt != nil {
+		return nil, errors.New("two-factor authentication already enabled")
+	}
+
+	if err := m.checkTOTP(enrollment, input.Code, ctx); err != nil {
+		return nil, err
+	}
+
+	if err := m.mfaRepo.ConfirmTOTP(userId, ctx); err != nil {
+		return nil, fmt.Errorf("failed to confirm totp, error: %w", err)
+	}
+
+	return m.newRecoveryCodes(userId, ctx)
+}
+
+func (m *mfaUsecase) DisableTOTP(userId int, input domain.TOTPCodeRequest, ctx context.Context) error {
+	enrollment, err := m.confirmedTOTP(userId, ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := m.checkTOTP(enrollment, input.Code, ctx); err != nil {
+		return err
+	}
+
+	if err := m.mfaRepo.DeleteTOTP(userId, ctx); err != nil {
+		return fmt.Errorf("failed to disable totp, error: %w", err)
+	}
+
+	return nil
+}
+
+func (m *mfaUsecase) RegenerateRecoveryCodes(userId int, input domain.TOTPCodeRequest, ctx context.Context) ([]string, error) {
+	enrollment, err := m.confirmedTOTP(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkTOTP(enrollment, input.Code, ctx); err != nil {
+		return nil, err
+	}
+
+	return m.newRecoveryCodes(userId, ctx)
+}
+
+// BeginWebAuthn starts a passkey assertion for the user behind a pending
+// MFA challenge.
+func (m *mfaUsecase) BeginWebAuthn(input domain.MFAWebAuthnRequest, ctx context.Context) (*domain.WebAuthnBeginResponse, error) {
+	challenge, err := m.mfaRepo.GetChallenge(utils.HashToken(input.MFAToken), ctx)
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.New("invalid or expired mfa token")
+	}
+
+	return m.webauthn.BeginSecondFactor(challenge.UserId, ctx)
+}
+
+// Verify completes a login that was paused for a second factor. Each
+// challenge allows a limited number of attempts and can be used only once,
+// and every wrong answer counts toward the account lockout.
+func (m *mfaUsecase) Verify(input domain.MFAVerifyRequest, ctx context.Context) (*domain.LoginResult, error) {
+	if input.Code == "" && input.RecoveryCode == "" && len(input.WebAuthn) == 0 {
+		return nil, errors.New("code, recovery code or passkey is required")
+	}
+
+	tokenHash := utils.HashToken(input.MFAToken)
+
+	challenge, err := m.mfaRepo.GetChallenge(tokenHash, ctx)
+	if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, errors.New("invalid or expired mfa token")
+	}
+
+	if err := m.userUsecase.CheckLoginThrottle(challenge.UserId, input.IPAddress, ctx); err != nil {
+		return nil, err
+	}
+
+	allowed, err := m.mfaRepo.IncrementChallengeAttempts(tokenHash, mfaMaxAttempts, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.New("too many attempts, please log in again")
+	}
+
+	switch {
+	case len(input.WebAuthn) > 0:
+		err = m.webauthn.VerifySecondFactor(challenge.UserId, input.CeremonyToken, input.WebAuthn, ctx)
+	case input.RecoveryCode != "":
+		err = m.useRecoveryCode(challenge.UserId, input.RecoveryCode, ctx)
+	default:
+		var enrollment *domain.TOTPEnrollment
+		enrollment, err = m.confirmedTOTP(challenge.UserId, ctx)
+		if err == nil {
+			err = m.checkTOTP(enrollment, input.Code, ctx)
+		}
+	}
+	if err != nil {
+		if lockErr := m.userUsecase.RecordLoginFailure(challenge.UserId, input.IPAddress, ctx); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, err
+	}
+
+	consumed, err := m.mfaRepo.ConsumeChallenge(tokenHash, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, errors.New("invalid or expired mfa token")
+	}
+
+	user, err := m.userUsecase.GetProfile(challenge.UserId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session := domain.Session{
+		UserId:    challenge.UserId,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	return m.userUsecase.CompleteMultiFactorLogin(user, &session, ctx)
+}
+
+func (m *mfaUsecase) confirmedTOTP(userId int, ctx context.Context) (*domain.TOTPEnrollment, error) {
+	enrollment, err := m.mfaRepo.GetTOTP(userId, ctx)
+	if err != nil || enrollment.ConfirmedAt == nil {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	return enrollment, nil
+}
+
+// checkTOTP accepts a code from the current or an adjacent time step and
+// records the step so the same code cannot be replayed.
+func (m *mfaUsecase) checkTOTP(enrollment *domain.TOTPEnrollment, code string, ctx context.Context) error {
+	secret, err := utils.DecryptString(m.encryptionKey, enrollment.SecretEncrypted)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret, error: %w", err)
+	}
+
+	step, ok := totp.Validate(secret, strings.TrimSpace(code), time.Now(), 1)
+	if !ok || step <= enrollment.LastUsedStep {
+		return errors.New("invalid code")
+	}
+
+	used, err := m.mfaRepo.UseTOTPStep(enrollment.UserId, step, ctx)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid code")
+	}
+
+	return nil
+}
+
+func (m *mfaUsecase) useRecoveryCode(userId int, code string, ctx context.Context) error {
+	codes, err := m.mfaRepo.ListUnusedRecoveryCodes(userId, ctx)
+	if err != nil {
+		return err
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	for _, c := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(c.CodeHash), []byte(normalized)) != nil {
+			continue
+		}
+
+		used, err := m.mfaRepo.UseRecoveryCode(c.Id, ctx)
+		if err != nil {
+			return err
+		}
+		if used {
+			return nil
+		}
+	}
+
+	return errors.New("invalid recovery code")
+}
+
+func (m *mfaUsecase) newRecoveryCodes(userId int, ctx context.Context) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.New("failed to generate recovery codes")
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes[i] = code[:8] + "-" + code[8:]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = string(hash)
+	}
+
+	if err := m.mfaRepo.ReplaceRecoveryCodes(userId, hashes, ctx); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes, error: %w", err)
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,447 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/totp"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type memoryMFARepository struct {
+	enrollments   map[int]*domain.TOTPEnrollment
+	recoveryCodes []domain.RecoveryCode
+	nextCodeId    int
+	challenges    map[string]*domain.MFAChallenge
+}
+
+func newMemoryMFARepository() *memoryMFARepository {
+	return &memoryMFARepository{
+		enrollments: map[int]*domain.TOTPEnrollment{},
+		challenges:  map[string]*domain.MFAChallenge{},
+	}
+}
+
+func (r *memoryMFARepository) SaveTOTP(enrollment *domain.TOTPEnrollment, ctx context.Context) error {
+	stored := *enrollment
+	r.enrollments[enrollment.UserId] = &stored
+	return nil
+}
+
+func (r *memoryMFARepository) GetTOTP(userId int, ctx context.Context) (*domain.TOTPEnrollment, error) {
+	stored, found := r.enrollments[userId]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	enrollment := *stored
+	return &enrollment, nil
+}
+
+func (r *memoryMFARepository) ConfirmTOTP(userId int, ctx context.Context) error {
+	now := time.Now()
+	r.enrollments[userId].ConfirmedAt = &now
+	return nil
+}
+
+func (r *memoryMFARepository) UseTOTPStep(userId int, step int64, ctx context.Context) (bool, error) {
+	stored, found := r.enrollments[userId]
+	if !found || step <= stored.LastUsedStep {
+		return false, nil
+	}
+
+	stored.LastUsedStep = step
+	return true, nil
+}
+
+func (r *memoryMFARepository) DeleteTOTP(userId int, ctx context.Context) error {
+	delete(r.enrollments, userId)
+	return nil
+}
+
+func (r *memoryMFARepository) ReplaceRecoveryCodes(userId int, hashes []string, ctx context.Context) error {
+	codes := []domain.RecoveryCode{}
+	for _, c := range r.recoveryCodes {
+		if c.UserId != userId {
+			codes = append(codes, c)
+		}
+	}
+	for _, hash := range hashes {
+		r.nextCodeId++
+		codes = append(codes, domain.RecoveryCode{Id: r.nextCodeId, UserId: userId, CodeHash: hash})
+	}
+
+	r.recoveryCodes = codes
+	return nil
+}
+
+func (r *memoryMFARepository) ListUnusedRecoveryCodes(userId int, ctx context.Context) ([]domain.RecoveryCode, error) {
+	codes := []domain.RecoveryCode{}
+	for _, c := range r.recoveryCodes {
+		if c.UserId == userId && c.UsedAt == nil {
+			codes = append(codes, c)
+		}
+	}
+
+	return codes, nil
+}
+
+func (r *memoryMFARepository) UseRecoveryCode(id int, ctx context.Context) (bool, error) {
+	for i := range r.recoveryCodes {
+		if r.recoveryCodes[i].Id == id && r.recoveryCodes[i].UsedAt == nil {
+			now := time.Now()
+			r.recoveryCodes[i].UsedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryMFARepository) CreateChallenge(challenge *domain.MFAChallenge, ctx context.Context) error {
+	stored := *challenge
+	r.challenges[challenge.TokenHash] = &stored
+	return nil
+}
+
+func (r *memoryMFARepository) GetChallenge(tokenHash string, ctx context.Context) (*domain.MFAChallenge, error) {
+	stored, found := r.challenges[tokenHash]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	challenge := *stored
+	return &challenge, nil
+}
+
+func (r *memoryMFARepository) IncrementChallengeAttempts(tokenHash string, maxAttempts int, ctx context.Context) (bool, error) {
+	stored, found := r.challenges[tokenHash]
+	if !found || stored.Attempts >= maxAttempts {
+		return false, nil
+	}
+
+	stored.Attempts++
+	return true, nil
+}
+
+func (r *memoryMFARepository) ConsumeChallenge(tokenHash string, ctx context.Context) (bool, error) {
+	stored, found := r.challenges[tokenHash]
+	if !found || stored.UsedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	stored.UsedAt = &now
+	return true, nil
+}
+
+const (
+	mfaTestPassword = "correct horse battery staple"
+	mfaTestIP       = "203.0.113.7"
+)
+
+type mfaFixture struct {
+	usecase domain.MFAUsecase
+	logins  *userUsecase
+	repo    *memoryMFARepository
+	users   *stubUserRepository
+	mail    *mailer.MemoryMailer
+	secret  string
+}
+
+// newMFAFixture sets up user 1 with a confirmed TOTP authenticator and an
+// account lock after maxFailures failed logins.
+func newMFAFixture(t *testing.T, maxFailures int) *mfaFixture {
+	t.Helper()
+
+	hasher, err := utils.NewPasswordHasher(utils.PasswordHashConfig{Algorithm: utils.HashBcrypt, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := hasher.Hash(mfaTestPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	userRepo := &stubUserRepository{users: map[int]*domain.User{
+		1: {Id: 1, FullName: "Test User", Username: "test", Email: "test@example.com", Password: hash, EmailVerifiedAt: &now},
+	}}
+
+	tokenConfig := func(kind jwt.TokenType) jwt.TokenConfig {
+		return jwt.TokenConfig{
+			Keys:     jwt.NewHMACKeySet("test secret"),
+			Type:     kind,
+			Issuer:   "test",
+			Audience: []string{"test"},
+			Expiry:   time.Hour,
+		}
+	}
+
+	repo := newMemoryMFARepository()
+	mail := mailer.NewMemoryMailer()
+
+	logins := &userUsecase{
+		userRepo:         userRepo,
+		refreshTokenRepo: &stubRefreshTokenRepository{},
+		sessionRepo:      &stubSessionRepository{},
+		mfaRepo:          repo,
+		webauthnRepo:     newMemoryWebAuthnRepository(),
+		mailer:           mail,
+		hasher:           hasher,
+		verification:     NewEmailVerificationUsecase(nil, userRepo, nil, domain.EmailVerificationNone, "", false),
+		lockout:          LockoutConfig{MaxFailures: maxFailures, Duration: 15 * time.Minute, UnlockURL: "http://localhost/unlock"},
+		accessToken:      tokenConfig(jwt.AccessToken),
+		refreshToken:     tokenConfig(jwt.RefreshToken),
+		dummyHash:        hash,
+	}
+
+	key := make([]byte, 32)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := utils.EncryptString(key, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.enrollments[1] = &domain.TOTPEnrollment{UserId: 1, SecretEncrypted: encrypted, ConfirmedAt: &now}
+
+	return &mfaFixture{
+		usecase: NewMFAUsecase(repo, logins, nil, key, "Test"),
+		logins:  logins,
+		repo:    repo,
+		users:   userRepo,
+		mail:    mail,
+		secret:  secret,
+	}
+}
+
+// login enters the right password and returns the MFA token.
+func (f *mfaFixture) login(t *testing.T) (string, error) {
+	t.Helper()
+
+	result, err := f.logins.Login(domain.LoginRequest{Email: "test@example.com", Password: mfaTestPassword, IPAddress: mfaTestIP}, context.Background())
+	if err != nil {
+		return "", err
+	}
+	if !result.MFARequired || result.MFAToken == "" {
+		t.Fatalf("login result = %+v, want an MFA challenge", result)
+	}
+
+	return result.MFAToken, nil
+}
+
+// wrongCode returns a code no step within the skew window accepts.
+func (f *mfaFixture) wrongCode(t *testing.T) string {
+	t.Helper()
+
+	for n := 0; ; n++ {
+		code := fmt.Sprintf("%06d", n)
+		if _, ok := totp.Validate(f.secret, code, time.Now(), 1); !ok {
+			return code
+		}
+	}
+}
+
+func TestMFAVerifyFailuresLockAccount(t *testing.T) {
+	const maxFailures = 3
+	f := newMFAFixture(t, maxFailures)
+	ctx := context.Background()
+
+	// A fresh challenge after every failure, each following a correct
+	// password, must not clear the count.
+	for i := 1; i <= maxFailures; i++ {
+		mfaToken, err := f.login(t)
+		if err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+
+		_, err = f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: f.wrongCode(t), IPAddress: mfaTestIP}, ctx)
+		if i < maxFailures && (err == nil || errors.Is(err, domain.ErrAccountLocked)) {
+			t.Fatalf("attempt %d: err = %v, want a wrong code", i, err)
+		}
+		if i == maxFailures && !errors.Is(err, domain.ErrAccountLocked) {
+			t.Fatalf("attempt %d: err = %v, want ErrAccountLocked", i, err)
+		}
+	}
+
+	if _, err := f.login(t); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("login after lock: err = %v, want ErrAccountLocked", err)
+	}
+	if len(f.mail.Messages()) != 1 {
+		t.Fatalf("%d messages sent, want the lock notice", len(f.mail.Messages()))
+	}
+}
+
+func TestMFAVerifyRefusesLockedAccount(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	ctx := context.Background()
+
+	mfaToken, err := f.login(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	f.users.LockAccount(1, until, "", ctx)
+
+	code, err := totp.CodeAt(f.secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: code, IPAddress: mfaTestIP}, ctx); !errors.Is(err, domain.ErrAccountLocked) {
+		t.Fatalf("err = %v, want ErrAccountLocked", err)
+	}
+}
+
+func TestMFAVerifySuccessResetsFailures(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	ctx := context.Background()
+
+	mfaToken, err := f.login(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: f.wrongCode(t), IPAddress: mfaTestIP}, ctx); err == nil {
+		t.Fatal("wrong code accepted")
+	}
+
+	code, err := totp.CodeAt(f.secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: code, IPAddress: mfaTestIP}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AccessToken == "" {
+		t.Fatalf("result = %+v, want tokens", result)
+	}
+
+	account, _ := f.users.GetLoginFailure(1, "", ctx)
+	if account.Failures != 0 {
+		t.Fatalf("%d failures left after login, want 0", account.Failures)
+	}
+}
+
+func TestMFAVerifyRejectsReplayedCode(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	ctx := context.Background()
+	now := time.Now()
+
+	current, err := totp.CodeAt(f.secret, totp.Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := totp.CodeAt(f.secret, totp.Step(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mfaToken, err := f.login(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: current, IPAddress: mfaTestIP}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither the same step nor an earlier one still inside the skew window
+	// may be used again.
+	for _, code := range []string{current, previous} {
+		mfaToken, err := f.login(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: code, IPAddress: mfaTestIP}, ctx); err == nil {
+			t.Fatalf("code %s accepted after step %d was used", code, totp.Step(now))
+		}
+	}
+}
+
+func TestMFAVerifyChallengeIsSingleUse(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	ctx := context.Background()
+
+	mfaToken, err := f.login(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := totp.CodeAt(f.secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: code, IPAddress: mfaTestIP}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A later code on the used challenge must not start a second session.
+	next, err := totp.CodeAt(f.secret, totp.Step(time.Now())+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, Code: next, IPAddress: mfaTestIP}, ctx); err == nil {
+		t.Fatal("used challenge accepted again")
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	f := newMFAFixture(t, 10)
+	ctx := context.Background()
+
+	code, err := totp.CodeAt(f.secret, totp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes, err := f.usecase.RegenerateRecoveryCodes(1, domain.TOTPCodeRequest{Code: code}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	verify := func(recoveryCode string) error {
+		t.Helper()
+
+		mfaToken, err := f.login(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = f.usecase.Verify(domain.MFAVerifyRequest{MFAToken: mfaToken, RecoveryCode: recoveryCode, IPAddress: mfaTestIP}, ctx)
+		return err
+	}
+
+	// Codes are accepted however they are typed, but only once.
+	if err := verify(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := verify(codes[0]); err == nil {
+		t.Fatal("recovery code accepted twice")
+	}
+
+	if err := verify(codes[1]); err != nil {
+		t.Fatalf("another code after one was used: %v", err)
+	}
+
+	unused, _ := f.repo.ListUnusedRecoveryCodes(1, ctx)
+	if len(unused) != recoveryCodeCount-2 {
+		t.Fatalf("%d unused codes left, want %d", len(unused), recoveryCodeCount-2)
+	}
+}
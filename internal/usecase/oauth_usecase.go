@@ -0,0 +1,501 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const authorizationCodeExpiry = 5 * time.Minute
+
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+type oauthUsecase struct {
+	oauthRepo   domain.OAuthRepository
+	userUsecase domain.UserUsecase
+	accessToken jwt.TokenConfig
+	idToken     jwt.TokenConfig
+}
+
+// NewOAuthUsecase serves OAuth 2.0 and, when idToken has keys, OpenID
+// Connect. Without ID token keys the openid scope is refused.
+func NewOAuthUsecase(r domain.OAuthRepository, u domain.UserUsecase, accessToken, idToken jwt.TokenConfig) domain.OAuthUsecase {
+	return &oauthUsecase{
+		oauthRepo:   r,
+		userUsecase: u,
+		accessToken: accessToken,
+		idToken:     idToken,
+	}
+}
+
+func (o *oauthUsecase) RegisterClient(ownerId int, input domain.RegisterClientRequest, ctx context.Context) (*domain.OAuthClient, string, error) {
+	for _, uri := range input.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+
+	scopes := input.Scopes
+	if len(scopes) == 0 {
+		scopes = o.supportedScopes()
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(o.supportedScopes(), scope) {
+			return nil, "", fmt.Errorf("unsupported scope %q", scope)
+		}
+	}
+
+	clientId, err := jwt.NewTokenId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := domain.OAuthClient{
+		Id:           clientId,
+		Name:         input.Name,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       scopes,
+		Public:       input.Public,
+		OwnerId:      ownerId,
+	}
+
+	var secret string
+	if !input.Public {
+		secret, client.SecretHash, err = newClientSecret()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := o.oauthRepo.CreateClient(&client, ctx); err != nil {
+		return nil, "", fmt.Errorf("failed to create client, error: %w", err)
+	}
+
+	return &client, secret, nil
+}
+
+// CreateServiceAccount registers a confidential client that acts as its own
+// principal through the client_credentials grant. It has no owner, no
+// redirect URIs and can only hold service scopes.
+func (o *oauthUsecase) CreateServiceAccount(input domain.CreateServiceAccountRequest, ctx context.Context) (*domain.OAuthClient, string, error) {
+	if len(input.Scopes) == 0 {
+		return nil, "", errors.New("a service account needs at least one scope")
+	}
+	for _, scope := range input.Scopes {
+		if !slices.Contains(domain.ServiceScopes, scope) {
+			return nil, "", fmt.Errorf("unsupported service scope %q", scope)
+		}
+	}
+
+	clientId, err := jwt.NewTokenId()
+	if err != nil {
+		return nil, "", err
+	}
+
+	client := domain.OAuthClient{
+		Id:             clientId,
+		Name:           input.Name,
+		RedirectURIs:   []string{},
+		Scopes:         input.Scopes,
+		ServiceAccount: true,
+	}
+
+	secret, hash, err := newClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	client.SecretHash = hash
+
+	if err := o.oauthRepo.CreateClient(&client, ctx); err != nil {
+		return nil, "", fmt.Errorf("failed to create service account, error: %w", err)
+	}
+
+	return &client, secret, nil
+}
+
+// supportedScopes drops openid when no ID token key is configured.
+func (o *oauthUsecase) supportedScopes() []string {
+	if o.idToken.Keys == nil {
+		return slices.DeleteFunc(slices.Clone(domain.SupportedScopes), func(scope string) bool {
+			return scope == domain.ScopeOpenID
+		})
+	}
+
+	return domain.SupportedScopes
+}
+
+func newClientSecret() (string, string, error) {
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, string(hash), nil
+}
+
+// ValidateAuthorizeRequest checks everything that can be checked before the
+// user gives consent. Errors about the client or redirect URI must be shown
+// to the user and never redirected, so they are returned as plain errors;
+// everything else is an *domain.OAuthError meant for the redirect URI.
+func (o *oauthUsecase) ValidateAuthorizeRequest(input domain.AuthorizeRequest, ctx context.Context) (*domain.OAuthClient, string, []string, error) {
+	client, err := o.oauthRepo.GetClient(input.ClientId, ctx)
+	if err != nil || client.ServiceAccount {
+		return nil, "", nil, errors.New("unknown client")
+	}
+
+	redirectURI := input.RedirectURI
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return nil, "", nil, errors.New("redirect_uri is not registered for this client")
+	}
+
+	if input.ResponseType != "code" {
+		return client, redirectURI, nil, &domain.OAuthError{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+
+	if input.CodeChallenge == "" || input.CodeChallengeMethod != "S256" {
+		return client, redirectURI, nil, &domain.OAuthError{Code: "invalid_request", Description: "PKCE with code_challenge_method S256 is required"}
+	}
+
+	scopes := strings.Fields(input.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return client, redirectURI, nil, &domain.OAuthError{Code: "invalid_scope", Description: fmt.Sprintf("scope %q is not allowed for this client", scope)}
+		}
+		if !slices.Contains(o.supportedScopes(), scope) {
+			return client, redirectURI, nil, &domain.OAuthError{Code: "invalid_scope", Description: fmt.Sprintf("scope %q is not supported", scope)}
+		}
+	}
+
+	return client, redirectURI, scopes, nil
+}
+
+func (o *oauthUsecase) Authorize(userId int, input domain.AuthorizeRequest, ctx context.Context) (string, error) {
+	client, redirectURI, scopes, err := o.ValidateAuthorizeRequest(input, ctx)
+	if client == nil {
+		return "", err
+	}
+
+	var oauthErr *domain.OAuthError
+	if err == nil && !input.Approve {
+		oauthErr = &domain.OAuthError{Code: "access_denied", Description: "the user denied the request"}
+	}
+	if err != nil && !errors.As(err, &oauthErr) {
+		return "", err
+	}
+	if oauthErr != nil {
+		return buildRedirect(redirectURI, url.Values{
+			"error":             {oauthErr.Code},
+			"error_description": {oauthErr.Description},
+		}, input.State), nil
+	}
+
+	code, err := utils.RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	authCode := domain.AuthorizationCode{
+		CodeHash:            utils.HashToken(code),
+		ClientId:            client.Id,
+		UserId:              userId,
+		RedirectURI:         redirectURI,
+		Scope:               strings.Join(scopes, " "),
+		Nonce:               input.Nonce,
+		CodeChallenge:       input.CodeChallenge,
+		CodeChallengeMethod: input.CodeChallengeMethod,
+		ExpiresAt:           time.Now().Add(authorizationCodeExpiry),
+	}
+
+	if err := o.oauthRepo.SaveAuthorizationCode(&authCode, ctx); err != nil {
+		return "", fmt.Errorf("failed to save authorization code, error: %w", err)
+	}
+
+	return buildRedirect(redirectURI, url.Values{"code": {code}}, input.State), nil
+}
+
+func (o *oauthUsecase) Token(input domain.TokenRequest, ctx context.Context) (*domain.TokenResponse, error) {
+	client, err := o.authenticateClient(input.ClientId, input.ClientSecret, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch input.GrantType {
+	case "authorization_code":
+		return o.exchangeCode(client, input, ctx)
+	case "refresh_token":
+		return o.refresh(client, input, ctx)
+	case "client_credentials":
+		return o.clientCredentials(client, input, ctx)
+	default:
+		return nil, &domain.OAuthError{Code: "unsupported_grant_type", Description: fmt.Sprintf("grant type %q is not supported", input.GrantType)}
+	}
+}
+
+func (o *oauthUsecase) exchangeCode(client *domain.OAuthClient, input domain.TokenRequest, ctx context.Context) (*domain.TokenResponse, error) {
+	invalidGrant := &domain.OAuthError{Code: "invalid_grant", Description: "the authorization code is invalid or expired"}
+
+	code, err := o.oauthRepo.GetAuthorizationCode(utils.HashToken(input.Code), ctx)
+	if err != nil {
+		return nil, invalidGrant
+	}
+
+	if code.ClientId != client.Id || code.RedirectURI != input.RedirectURI || time.Now().After(code.ExpiresAt) {
+		return nil, invalidGrant
+	}
+
+	if !verifyCodeChallenge(input.CodeVerifier, code.CodeChallenge) {
+		return nil, &domain.OAuthError{Code: "invalid_grant", Description: "code_verifier does not match code_challenge"}
+	}
+
+	used, err := o.oauthRepo.MarkAuthorizationCodeUsed(code.CodeHash, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalidGrant
+	}
+
+	session := domain.Session{
+		UserId:    code.UserId,
+		ClientId:  client.Id,
+		Scope:     code.Scope,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	accessToken, refreshToken, err := o.userUsecase.StartSession(&session, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := domain.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(o.accessToken.Expiry.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        code.Scope,
+	}
+
+	if slices.Contains(strings.Fields(code.Scope), domain.ScopeOpenID) {
+		res.IDToken, err = o.issueIDToken(code, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &res, nil
+}
+
+func (o *oauthUsecase) issueIDToken(code *domain.AuthorizationCode, ctx context.Context) (string, error) {
+	info, err := o.UserInfo(code.UserId, code.Scope, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.IDTokenClaims{
+		Nonce:             code.Nonce,
+		Name:              info.Name,
+		PreferredUsername: info.PreferredUsername,
+		Email:             info.Email,
+		EmailVerified:     info.EmailVerified,
+	}
+
+	return jwt.GenerateIDToken(claims, code.UserId, code.ClientId, o.idToken)
+}
+
+// UserInfo returns the OpenID Connect standard claims the given scope
+// grants access to.
+func (o *oauthUsecase) UserInfo(userId int, scope string, ctx context.Context) (*domain.UserInfo, error) {
+	user, err := o.userUsecase.GetProfile(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(scope)
+	info := domain.UserInfo{Subject: strconv.Itoa(user.Id)}
+
+	if slices.Contains(scopes, domain.ScopeProfile) {
+		info.Name = user.FullName
+		info.PreferredUsername = user.Username
+	}
+
+	if slices.Contains(scopes, domain.ScopeEmail) {
+		info.Email = user.Email
+		verified := user.EmailVerifiedAt != nil
+		info.EmailVerified = &verified
+	}
+
+	return &info, nil
+}
+
+func (o *oauthUsecase) clientCredentials(client *domain.OAuthClient, input domain.TokenRequest, ctx context.Context) (*domain.TokenResponse, error) {
+	if !client.ServiceAccount {
+		return nil, &domain.OAuthError{Code: "unauthorized_client", Description: "only service accounts may use the client_credentials grant"}
+	}
+
+	scopes := strings.Fields(input.Scope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, &domain.OAuthError{Code: "invalid_scope", Description: fmt.Sprintf("scope %q is not allowed for this client", scope)}
+		}
+	}
+
+	scope := strings.Join(scopes, " ")
+	accessToken, _, err := jwt.GenerateToken(jwt.CustomClaims{ClientId: client.Id, Scope: scope}, o.accessToken)
+	if err != nil {
+		return nil, errors.New("failed to generate token")
+	}
+
+	return &domain.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(o.accessToken.Expiry.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+func (o *oauthUsecase) refresh(client *domain.OAuthClient, input domain.TokenRequest, ctx context.Context) (*domain.TokenResponse, error) {
+	accessToken, refreshToken, err := o.userUsecase.Refresh(domain.RefreshTokenRequest{
+		RefreshToken: input.RefreshToken,
+		ClientId:     client.Id,
+	}, ctx)
+	if err != nil {
+		return nil, &domain.OAuthError{Code: "invalid_grant", Description: err.Error()}
+	}
+
+	return &domain.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(o.accessToken.Expiry.Seconds()),
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Introspect is meant for resource servers and gateways, so only
+// confidential clients may call it.
+func (o *oauthUsecase) Introspect(input domain.IntrospectRequest, ctx context.Context) (*domain.TokenInfo, error) {
+	client, err := o.authenticateClient(input.ClientId, input.ClientSecret, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public {
+		return nil, &domain.OAuthError{Code: "unauthorized_client", Description: "public clients cannot introspect tokens"}
+	}
+
+	info, err := o.userUsecase.IntrospectToken(input.Token, ctx)
+	if err != nil {
+		return &domain.TokenInfo{Active: false}, nil
+	}
+
+	return info, nil
+}
+
+func (o *oauthUsecase) Revoke(input domain.RevokeRequest, ctx context.Context) error {
+	client, err := o.authenticateClient(input.ClientId, input.ClientSecret, ctx)
+	if err != nil {
+		return err
+	}
+
+	info, err := o.userUsecase.IntrospectToken(input.Token, ctx)
+	if err != nil {
+		return nil
+	}
+
+	if info.ClientId != client.Id {
+		return &domain.OAuthError{Code: "unauthorized_client", Description: "the token was not issued to this client"}
+	}
+
+	return o.userUsecase.RevokeToken(input.Token, ctx)
+}
+
+func (o *oauthUsecase) authenticateClient(clientId, secret string, ctx context.Context) (*domain.OAuthClient, error) {
+	invalidClient := &domain.OAuthError{Code: "invalid_client", Description: "client authentication failed"}
+
+	client, err := o.oauthRepo.GetClient(clientId, ctx)
+	if err != nil {
+		return nil, invalidClient
+	}
+
+	if client.Public {
+		if secret != "" {
+			return nil, invalidClient
+		}
+		return client, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)); err != nil {
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func validateRedirectURI(raw string) error {
+	uri, err := url.Parse(raw)
+	if err != nil || !uri.IsAbs() || uri.Fragment != "" {
+		return fmt.Errorf("invalid redirect uri %q", raw)
+	}
+
+	host := uri.Hostname()
+	if uri.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1") {
+		return nil
+	}
+
+	if uri.Scheme != "https" {
+		return fmt.Errorf("redirect uri %q must use https", raw)
+	}
+
+	return nil
+}
+
+func buildRedirect(redirectURI string, params url.Values, state string) string {
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	uri, _ := url.Parse(redirectURI)
+	query := uri.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	uri.RawQuery = query.Encode()
+
+	return uri.String()
+}
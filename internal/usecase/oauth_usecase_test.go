@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+)
+
+type memoryOAuthRepository struct {
+	clients map[string]*domain.OAuthClient
+	codes   map[string]*domain.AuthorizationCode
+}
+
+func (r *memoryOAuthRepository) CreateClient(client *domain.OAuthClient, ctx context.Context) error {
+	stored := *client
+	r.clients[client.Id] = &stored
+	return nil
+}
+
+func (r *memoryOAuthRepository) GetClient(id string, ctx context.Context) (*domain.OAuthClient, error) {
+	stored, found := r.clients[id]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	client := *stored
+	return &client, nil
+}
+
+func (r *memoryOAuthRepository) SaveAuthorizationCode(code *domain.AuthorizationCode, ctx context.Context) error {
+	stored := *code
+	r.codes[code.CodeHash] = &stored
+	return nil
+}
+
+func (r *memoryOAuthRepository) GetAuthorizationCode(codeHash string, ctx context.Context) (*domain.AuthorizationCode, error) {
+	stored, found := r.codes[codeHash]
+	if !found || stored.UsedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	code := *stored
+	return &code, nil
+}
+
+func (r *memoryOAuthRepository) MarkAuthorizationCodeUsed(codeHash string, ctx context.Context) (bool, error) {
+	stored, found := r.codes[codeHash]
+	if !found || stored.UsedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	stored.UsedAt = &now
+	return true, nil
+}
+
+const (
+	testRedirectURI = "http://localhost:5173/callback"
+
+	// The example pair from RFC 7636 appendix B.
+	testCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+)
+
+type oauthFixture struct {
+	usecase      domain.OAuthUsecase
+	accessToken  jwt.TokenConfig
+	public       *domain.OAuthClient
+	confidential *domain.OAuthClient
+	secret       string
+}
+
+func newOAuthFixture(t *testing.T) *oauthFixture {
+	t.Helper()
+	ctx := context.Background()
+
+	logins := newMFAFixture(t, 10).logins
+	repo := &memoryOAuthRepository{
+		clients: map[string]*domain.OAuthClient{},
+		codes:   map[string]*domain.AuthorizationCode{},
+	}
+
+	// No ID token keys, so the openid scope is off.
+	uc := NewOAuthUsecase(repo, logins, logins.accessToken, jwt.TokenConfig{})
+
+	public, _, err := uc.RegisterClient(1, domain.RegisterClientRequest{Name: "SPA", RedirectURIs: []string{testRedirectURI}, Public: true}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confidential, secret, err := uc.RegisterClient(1, domain.RegisterClientRequest{Name: "Backend", RedirectURIs: []string{testRedirectURI}}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &oauthFixture{
+		usecase:      uc,
+		accessToken:  logins.accessToken,
+		public:       public,
+		confidential: confidential,
+		secret:       secret,
+	}
+}
+
+func (f *oauthFixture) authorizeRequest(clientId string) domain.AuthorizeRequest {
+	return domain.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientId:            clientId,
+		RedirectURI:         testRedirectURI,
+		Scope:               "profile",
+		State:               "xyz",
+		CodeChallenge:       testCodeChallenge,
+		CodeChallengeMethod: "S256",
+		Approve:             true,
+	}
+}
+
+// authorize approves the request for user 1 and returns the redirect.
+func (f *oauthFixture) authorize(t *testing.T, input domain.AuthorizeRequest) url.Values {
+	t.Helper()
+
+	redirect, err := f.usecase.Authorize(1, input, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(redirect, testRedirectURI+"?") {
+		t.Fatalf("redirected to %s, want %s", redirect, testRedirectURI)
+	}
+
+	query := uri.Query()
+	if query.Get("state") != input.State {
+		t.Fatalf("state = %q, want %q", query.Get("state"), input.State)
+	}
+
+	return query
+}
+
+func (f *oauthFixture) code(t *testing.T) string {
+	t.Helper()
+
+	query := f.authorize(t, f.authorizeRequest(f.public.Id))
+	if query.Get("code") == "" {
+		t.Fatalf("no code in redirect: %v", query)
+	}
+
+	return query.Get("code")
+}
+
+func (f *oauthFixture) exchange(code, redirectURI, verifier string) (*domain.TokenResponse, error) {
+	return f.usecase.Token(domain.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  redirectURI,
+		CodeVerifier: verifier,
+		ClientId:     f.public.Id,
+	}, context.Background())
+}
+
+func wantOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	var oauthErr *domain.OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != code {
+		t.Fatalf("err = %v, want OAuth error %s", err, code)
+	}
+}
+
+func TestOAuthAuthorizationCodeWithPKCE(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	res, err := f.exchange(f.code(t), testRedirectURI, testCodeVerifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.TokenType != "Bearer" || res.RefreshToken == "" || res.Scope != "profile" || res.IDToken != "" {
+		t.Fatalf("token response = %+v", res)
+	}
+
+	claims, err := jwt.ValidateToken(res.AccessToken, f.accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId != 1 || claims.ClientId != f.public.Id || claims.Scope != "profile" {
+		t.Fatalf("access token claims = %+v", claims)
+	}
+}
+
+func TestOAuthCodeVerifier(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		verifier string
+	}{
+		{"missing", ""},
+		{"wrong", strings.Repeat("a", 43)},
+		{"too short", testCodeVerifier[:42]},
+		{"too long", strings.Repeat("a", 129)},
+		{"bad characters", testCodeVerifier[:42] + "+"},
+		{"challenge itself", testCodeChallenge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOAuthFixture(t)
+			code := f.code(t)
+
+			_, err := f.exchange(code, testRedirectURI, tt.verifier)
+			wantOAuthError(t, err, "invalid_grant")
+
+			// A failed verifier must not burn the code for the real client.
+			if _, err := f.exchange(code, testRedirectURI, testCodeVerifier); err != nil {
+				t.Fatalf("right verifier after a wrong one: %v", err)
+			}
+		})
+	}
+}
+
+func TestOAuthAuthorizeRequiresS256(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	for _, tt := range []struct {
+		challenge string
+		method    string
+	}{
+		{"", ""},
+		{testCodeChallenge, ""},
+		{testCodeVerifier, "plain"},
+		{"", "S256"},
+	} {
+		input := f.authorizeRequest(f.public.Id)
+		input.CodeChallenge, input.CodeChallengeMethod = tt.challenge, tt.method
+
+		query := f.authorize(t, input)
+		if query.Get("error") != "invalid_request" || query.Get("code") != "" {
+			t.Errorf("challenge %q method %q: redirect %v, want invalid_request", tt.challenge, tt.method, query)
+		}
+	}
+}
+
+func TestOAuthRedirectURIMustMatchExactly(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+
+	for _, uri := range []string{
+		"",
+		testRedirectURI + "/",
+		testRedirectURI + "?next=/admin",
+		testRedirectURI + "#x",
+		"http://localhost:5173/Callback",
+		"http://localhost:5174/callback",
+		"http://evil.example/callback",
+	} {
+		input := f.authorizeRequest(f.public.Id)
+		input.RedirectURI = uri
+
+		// Never redirect to an unregistered URI, not even with an error.
+		redirect, err := f.usecase.Authorize(1, input, ctx)
+		var oauthErr *domain.OAuthError
+		if err == nil || errors.As(err, &oauthErr) || redirect != "" {
+			t.Errorf("redirect_uri %q: redirect %q, err %v, want a plain error", uri, redirect, err)
+		}
+	}
+
+	// The code is bound to the URI it was issued for.
+	code := f.code(t)
+	_, err := f.exchange(code, testRedirectURI+"/", testCodeVerifier)
+	wantOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthCodeIsSingleUse(t *testing.T) {
+	f := newOAuthFixture(t)
+	code := f.code(t)
+
+	if _, err := f.exchange(code, testRedirectURI, testCodeVerifier); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := f.exchange(code, testRedirectURI, testCodeVerifier)
+	wantOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthCodeIsBoundToClient(t *testing.T) {
+	f := newOAuthFixture(t)
+
+	_, err := f.usecase.Token(domain.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         f.code(t),
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testCodeVerifier,
+		ClientId:     f.confidential.Id,
+		ClientSecret: f.secret,
+	}, context.Background())
+	wantOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	f := newOAuthFixture(t)
+	ctx := context.Background()
+
+	service, serviceSecret, err := f.usecase.CreateServiceAccount(domain.CreateServiceAccountRequest{Name: "Ops", Scopes: []string{domain.ScopeUsersRead}}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		clientId string
+		secret   string
+		scope    string
+		wantErr  string
+	}{
+		{name: "service account", clientId: service.Id, secret: serviceSecret},
+		{name: "granted scope", clientId: service.Id, secret: serviceSecret, scope: domain.ScopeUsersRead},
+		{name: "scope not granted", clientId: service.Id, secret: serviceSecret, scope: domain.ScopeUsersUnlock, wantErr: "invalid_scope"},
+		{name: "wrong secret", clientId: service.Id, secret: "wrong", wantErr: "invalid_client"},
+		{name: "confidential client", clientId: f.confidential.Id, secret: f.secret, wantErr: "unauthorized_client"},
+		{name: "public client", clientId: f.public.Id, wantErr: "unauthorized_client"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := f.usecase.Token(domain.TokenRequest{
+				GrantType:    "client_credentials",
+				Scope:        tt.scope,
+				ClientId:     tt.clientId,
+				ClientSecret: tt.secret,
+			}, ctx)
+			if tt.wantErr != "" {
+				wantOAuthError(t, err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := jwt.ValidateToken(res.AccessToken, f.accessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserId != 0 || claims.ClientId != service.Id || claims.Scope != domain.ScopeUsersRead || res.RefreshToken != "" {
+				t.Fatalf("claims = %+v, refresh token %q", claims, res.RefreshToken)
+			}
+		})
+	}
+
+	// Service accounts cannot act for a user through the code flow.
+	if _, _, _, err := f.usecase.ValidateAuthorizeRequest(f.authorizeRequest(service.Id), ctx); err == nil {
+		t.Fatal("service account accepted as an authorization code client")
+	}
+}
@@ -2,47 +2,125 @@ package usecase
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
-	"math/rand"
-	"os"
+	"net/url"
 	"time"
 
 	"github.com/Hdeee1/go-register-login-profile/internal/domain"
 	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/pwned"
 	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	otpExpiry         = 5 * time.Minute
+	otpResendCooldown = time.Minute
+	otpMaxAttempts    = 5
+)
+
+// hideAccountError drops an error that would tell the caller whether an
+// account exists, when enumeration protection is on.
+func hideAccountError(hide bool, err error) error {
+	if hide {
+		return nil
+	}
+
+	return err
+}
+
+// PasswordResetConfig picks how ForgotPassword delivers the reset when the
+// request does not ask for a method. Secret keys the hash of stored reset
+// codes.
+type PasswordResetConfig struct {
+	Method  string
+	LinkURL string
+	LinkTTL time.Duration
+	Secret  []byte
+}
+
 type userUsecase struct {
-	userRepo domain.UserRepository
+	userRepo         domain.UserRepository
+	refreshTokenRepo domain.RefreshTokenRepository
+	sessionRepo      domain.SessionRepository
+	mfaRepo          domain.MFARepository
+	webauthnRepo     domain.WebAuthnRepository
+	blacklist        jwt.Blacklist
+	mailer           mailer.Mailer
+	hasher           utils.PasswordHasher
+	breached         pwned.Checker
+	passwordPolicy   utils.PasswordPolicy
+	verification     domain.EmailVerificationUsecase
+	passwordReset    PasswordResetConfig
+	lockout          LockoutConfig
+	hideAccounts     bool
+	accessToken      jwt.TokenConfig
+	refreshToken     jwt.TokenConfig
+
+	// dummyHash is compared against when the account does not exist, so a
+	// failed login takes as long as one with a wrong password.
+	dummyHash string
 }
 
-func NewUserUsecase(r domain.UserRepository) domain.UserUsecase {
-	return &userUsecase{userRepo: r}
+func NewUserUsecase(r domain.UserRepository, rt domain.RefreshTokenRepository, s domain.SessionRepository, m domain.MFARepository, w domain.WebAuthnRepository, b jwt.Blacklist, ml mailer.Mailer, hasher utils.PasswordHasher, breached pwned.Checker, passwordPolicy utils.PasswordPolicy, v domain.EmailVerificationUsecase, passwordReset PasswordResetConfig, lockout LockoutConfig, hideAccounts bool, accessToken, refreshToken jwt.TokenConfig) domain.UserUsecase {
+	dummyHash, _ := hasher.Hash("dummy password")
+
+	return &userUsecase{
+		userRepo:         r,
+		refreshTokenRepo: rt,
+		sessionRepo:      s,
+		mfaRepo:          m,
+		webauthnRepo:     w,
+		blacklist:        b,
+		mailer:           ml,
+		hasher:           hasher,
+		breached:         breached,
+		passwordPolicy:   passwordPolicy,
+		verification:     v,
+		passwordReset:    passwordReset,
+		lockout:          lockout,
+		hideAccounts:     hideAccounts,
+		accessToken:      accessToken,
+		refreshToken:     refreshToken,
+		dummyHash:        dummyHash,
+	}
 }
 
+// Register creates the account. With enumeration protection on it returns
+// no user, and an email that is already registered gets a notice instead of
+// a second account, so the response is the same either way. A taken
+// username is likewise reported by email to the address signing up. The
+// password is checked first for the same reason: a weak password must be
+// rejected whether or not the email is taken.
 func (u *userUsecase) Register(input domain.RegisterRequest, ctx context.Context) (*domain.User, error) {
+	if err := u.checkPassword(input.Password, input.Username, input.Email, input.FullName); err != nil {
+		return nil, err
+	}
+
 	data, err := u.userRepo.FindByEmailOrUsername(input.Email, input.Username)
 	if err == nil && data != nil {
 		if data.Email == input.Email {
+			if u.hideAccounts {
+				return nil, u.sendAccountExists(data, ctx)
+			}
 			return nil, errors.New("email already registered")
 		}
 		if data.Username == input.Username {
+			if u.hideAccounts {
+				return nil, u.sendUsernameTaken(input, ctx)
+			}
 			return nil, errors.New("username already taken")
 		}
 	}
 
-	if err := utils.ValidatePassword(input.Password); err != nil {
-		return nil, err
-	}
-
-	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	hash, err := u.hasher.Hash(input.Password)
 	if err != nil {
 		return nil, err
 	}
 
-	input.Password = string(hash)
+	input.Password = hash
 
 	var user domain.User
 	user.FullName = input.FullName
@@ -54,10 +132,83 @@ func (u *userUsecase) Register(input domain.RegisterRequest, ctx context.Context
 		return nil, fmt.Errorf("failed to create user, error: %w", err)
 	}
 
+	// The account exists even if the email cannot be queued; the user can
+	// ask for another one.
+	u.verification.Send(&user, ctx)
+
+	if u.hideAccounts {
+		return nil, nil
+	}
+
 	return &user, nil
 }
 
-func (u *userUsecase) Login(input domain.LoginRequest, ctx context.Context) (*domain.User, string, string, error) {
+// checkPassword applies the password policy and, when a breach corpus is
+// configured, rejects passwords that are known to have leaked. Every broken
+// rule is reported in a *utils.PasswordPolicyError.
+func (u *userUsecase) checkPassword(password string, userInputs ...string) error {
+	violations := u.passwordPolicy.Check(password, userInputs...)
+
+	if u.breached != nil {
+		breached, err := u.breached.Breached(password)
+		if err != nil {
+			return fmt.Errorf("failed to check password, error: %w", err)
+		}
+		if breached {
+			violations = append(violations, utils.PasswordViolation{
+				Rule:    utils.RuleBreached,
+				Message: "password has appeared in a data breach",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &utils.PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (u *userUsecase) sendAccountExists(user *domain.User, ctx context.Context) error {
+	// Hashing takes most of the time of a real registration.
+	u.hasher.Compare(u.dummyHash, user.Email)
+
+	msg, err := mailer.Render(mailer.TemplateAccountExists, user.Email, map[string]any{
+		"Name":     user.FullName,
+		"Username": user.Username,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.mailer.Send(msg, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+// sendUsernameTaken tells the owner of a new address that the username is
+// in use. Answering the request directly would show which usernames exist.
+func (u *userUsecase) sendUsernameTaken(input domain.RegisterRequest, ctx context.Context) error {
+	u.hasher.Compare(u.dummyHash, input.Password)
+
+	msg, err := mailer.Render(mailer.TemplateUsernameTaken, input.Email, map[string]any{
+		"Name":     input.FullName,
+		"Username": input.Username,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := u.mailer.Send(msg, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
+	}
+
+	return nil
+}
+
+func (u *userUsecase) Login(input domain.LoginRequest, ctx context.Context) (*domain.LoginResult, error) {
 	password := input.Password
 
 	var user domain.User
@@ -65,44 +216,342 @@ func (u *userUsecase) Login(input domain.LoginRequest, ctx context.Context) (*do
 	user.Password = input.Password
 
 	if err := u.userRepo.GetByEmail(&user, ctx); err != nil {
-		return nil, "", "", errors.New("wrong email or password")
+		u.hasher.Compare(u.dummyHash, password)
+		return nil, errors.New("wrong email or password")
+	}
+
+	if err := u.checkLoginThrottle(user.Id, input.IPAddress, ctx); err != nil {
+		if u.hideAccounts {
+			u.hasher.Compare(u.dummyHash, password)
+		}
+		return nil, u.hideLoginThrottle(err)
+	}
+
+	if err := u.hasher.Compare(user.Password, password); err != nil {
+		if err := u.recordLoginFailure(&user, input.IPAddress, ctx); err != nil {
+			return nil, u.hideLoginThrottle(err)
+		}
+		return nil, errors.New("wrong email or password")
+	}
+
+	// Upgrade hashes made with an older algorithm or cost while the plain
+	// password is at hand. A failed upgrade does not block the login.
+	if u.hasher.NeedsRehash(user.Password) {
+		if hash, err := u.hasher.Hash(password); err == nil {
+			u.userRepo.RehashPassword(user.Id, user.Password, hash, ctx)
+		}
+	}
+
+	session := domain.Session{
+		UserId:    user.Id,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	return u.CompleteLogin(&user, &session, ctx)
+}
+
+// CompleteLogin finishes a login once the first factor has been checked. It
+// either starts the session or, if the user has a second factor set up,
+// returns an MFA challenge instead.
+func (u *userUsecase) CompleteLogin(user *domain.User, session *domain.Session, ctx context.Context) (*domain.LoginResult, error) {
+	if err := u.verification.CheckLogin(user); err != nil {
+		return nil, err
+	}
+
+	if methods := u.mfaMethods(user.Id, ctx); len(methods) > 0 {
+		mfaToken, err := u.createMFAChallenge(user.Id, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &domain.LoginResult{User: user, MFARequired: true, MFAToken: mfaToken, MFAMethods: methods}, nil
+	}
+
+	return u.startLoginSession(user, session, ctx)
+}
+
+// CompleteMultiFactorLogin is CompleteLogin once two factors are proven,
+// either by a passed MFA challenge or by a first step such as a passkey with
+// user verification. The account checks still apply; only the MFA challenge
+// is skipped.
+func (u *userUsecase) CompleteMultiFactorLogin(user *domain.User, session *domain.Session, ctx context.Context) (*domain.LoginResult, error) {
+	if err := u.verification.CheckLogin(user); err != nil {
+		return nil, err
+	}
+
+	return u.startLoginSession(user, session, ctx)
+}
+
+// startLoginSession forgets the failed logins only once every factor has
+// passed; a correct password alone must not clear failed second factors.
+func (u *userUsecase) startLoginSession(user *domain.User, session *domain.Session, ctx context.Context) (*domain.LoginResult, error) {
+	if err := u.userRepo.ResetLoginFailures(user.Id, session.IPAddress, ctx); err != nil {
+		return nil, err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, "", "", errors.New("wrong email or password")
+	accessToken, refreshToken, err := u.StartSession(session, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.LoginResult{User: user, AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// mfaMethods lists the second factors the user has set up. Any registered
+// passkey can serve as one, as can a confirmed TOTP authenticator.
+func (u *userUsecase) mfaMethods(userId int, ctx context.Context) []string {
+	methods := []string{}
+
+	if enrollment, err := u.mfaRepo.GetTOTP(userId, ctx); err == nil && enrollment.ConfirmedAt != nil {
+		methods = append(methods, domain.MFAMethodTOTP)
+	}
+
+	if credentials, err := u.webauthnRepo.ListCredentialsByUser(userId, ctx); err == nil && len(credentials) > 0 {
+		methods = append(methods, domain.MFAMethodWebAuthn)
+	}
+
+	return methods
+}
+
+func (u *userUsecase) createMFAChallenge(userId int, ctx context.Context) (string, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", errors.New("failed to generate token")
+	}
+
+	challenge := domain.MFAChallenge{
+		TokenHash: utils.HashToken(token),
+		UserId:    userId,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+
+	if err := u.mfaRepo.CreateChallenge(&challenge, ctx); err != nil {
+		return "", fmt.Errorf("failed to create mfa challenge, error: %w", err)
+	}
+
+	return token, nil
+}
+
+func (u *userUsecase) StartSession(session *domain.Session, ctx context.Context) (string, string, error) {
+	sessionId, err := jwt.NewTokenId()
+	if err != nil {
+		return "", "", errors.New("failed to generate token")
 	}
+	session.Id = sessionId
 
-	accessKey := os.Getenv("JWT_ACCESS_SECRET")
-	accessToken, err := jwt.GenerateToken(user.Id, accessKey, 1*time.Hour)
+	accessToken, refreshToken, refreshId, err := u.issueTokens(session, ctx)
 	if err != nil {
-		return nil, "", "", errors.New("failed to generate token")
+		return "", "", err
+	}
+	session.RefreshTokenId = refreshId
+
+	if err := u.sessionRepo.Create(session, ctx); err != nil {
+		return "", "", fmt.Errorf("failed to create session, error: %w", err)
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (u *userUsecase) Refresh(input domain.RefreshTokenRequest, ctx context.Context) (string, string, error) {
+	claims, err := jwt.ValidateToken(input.RefreshToken, u.refreshToken)
+	if err != nil {
+		return "", "", errors.New("invalid token")
+	}
+
+	stored, err := u.refreshTokenRepo.GetById(claims.ID, ctx)
+	if err != nil {
+		return "", "", errors.New("invalid token")
+	}
+
+	if stored.RevokedAt != nil {
+		return "", "", errors.New("invalid token")
+	}
+
+	if stored.ReplacedBy != "" {
+		u.revokeFamily(stored.FamilyId, ctx)
+		return "", "", errors.New("refresh token reuse detected")
+	}
+
+	session, err := u.sessionRepo.GetById(stored.FamilyId, ctx)
+	if err != nil || session.RevokedAt != nil || session.ClientId != input.ClientId {
+		return "", "", errors.New("invalid token")
+	}
+
+	if !u.tokenVersionCurrent(claims, ctx) {
+		return "", "", errors.New("invalid token")
+	}
+
+	accessToken, refreshToken, refreshId, err := u.issueTokens(session, ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	rotated, err := u.refreshTokenRepo.Rotate(stored.Id, refreshId, ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !rotated {
+		u.revokeFamily(stored.FamilyId, ctx)
+		return "", "", errors.New("refresh token reuse detected")
+	}
+
+	if err := u.sessionRepo.Touch(stored.FamilyId, refreshId, ctx); err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+func (u *userUsecase) IntrospectToken(token string, ctx context.Context) (*domain.TokenInfo, error) {
+	inactive := errors.New("token is not active")
+
+	if claims, err := jwt.ValidateToken(token, u.accessToken); err == nil {
+		blacklisted, err := u.blacklist.IsBlacklisted(claims.ID, ctx)
+		if err != nil || blacklisted || !u.sessionActive(claims, ctx) {
+			return nil, inactive
+		}
+		return newTokenInfo(claims, "access_token"), nil
 	}
 
-	refreshKey := os.Getenv("JWT_REFRESH_SECRET")
-	refreshToken, err := jwt.GenerateToken(user.Id, refreshKey, 24*time.Hour)
+	if claims, err := jwt.ValidateToken(token, u.refreshToken); err == nil {
+		stored, err := u.refreshTokenRepo.GetById(claims.ID, ctx)
+		if err != nil || stored.RevokedAt != nil || stored.ReplacedBy != "" || !u.sessionActive(claims, ctx) {
+			return nil, inactive
+		}
+		return newTokenInfo(claims, "refresh_token"), nil
+	}
+
+	return nil, inactive
+}
+
+// RevokeToken blacklists an access token, or ends the whole session behind a
+// refresh token so the access tokens issued alongside it stop working too.
+// Tokens that are already invalid are ignored.
+func (u *userUsecase) RevokeToken(token string, ctx context.Context) error {
+	info, err := u.IntrospectToken(token, ctx)
 	if err != nil {
-		return nil, "", "", errors.New("failed to generate token")
+		return nil
+	}
+
+	if info.TokenType == "refresh_token" {
+		u.revokeFamily(info.SessionId, ctx)
+		return nil
+	}
+
+	return u.blacklist.Add(info.TokenId, time.Unix(info.ExpiresAt, 0), ctx)
+}
+
+func (u *userUsecase) sessionActive(claims *jwt.CustomClaims, ctx context.Context) bool {
+	if claims.IsServiceAccount() {
+		return true
 	}
 
-	return &user, accessToken, refreshToken, nil
+	session, err := u.sessionRepo.GetById(claims.SessionId, ctx)
+	return err == nil && session.UserId == claims.UserId && session.RevokedAt == nil && u.tokenVersionCurrent(claims, ctx)
 }
 
-func (u *userUsecase) Refresh(input domain.RefreshTokenRequest, ctx context.Context) (string, error) {
-	refreshToken := input.RefreshToken
+// tokenVersionCurrent reports whether the token was issued after the user's
+// last password change.
+func (u *userUsecase) tokenVersionCurrent(claims *jwt.CustomClaims, ctx context.Context) bool {
+	version, err := u.userRepo.GetTokenVersion(claims.UserId, ctx)
+	return err == nil && version == claims.TokenVersion
+}
+
+func newTokenInfo(claims *jwt.CustomClaims, tokenType string) *domain.TokenInfo {
+	info := domain.TokenInfo{
+		Active:    true,
+		TokenType: tokenType,
+		Scope:     claims.Scope,
+		ClientId:  claims.ClientId,
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		TokenId:   claims.ID,
+		UserId:    claims.UserId,
+		SessionId: claims.SessionId,
+	}
+
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		info.NotBefore = claims.NotBefore.Unix()
+	}
 
-	refreshKey := os.Getenv("JWT_REFRESH_SECRET")
-	claims, err := jwt.ValidateToken(refreshToken, refreshKey)
+	return &info
+}
+
+func (u *userUsecase) revokeFamily(familyId string, ctx context.Context) {
+	u.refreshTokenRepo.RevokeFamily(familyId, ctx)
+	u.sessionRepo.Revoke(familyId, ctx)
+}
+
+func (u *userUsecase) issueTokens(session *domain.Session, ctx context.Context) (string, string, string, error) {
+	version, err := u.userRepo.GetTokenVersion(session.UserId, ctx)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get token version, error: %w", err)
+	}
+
+	claims := jwt.CustomClaims{
+		UserId:       session.UserId,
+		SessionId:    session.Id,
+		ClientId:     session.ClientId,
+		Scope:        session.Scope,
+		TokenVersion: version,
+	}
+
+	accessToken, _, err := jwt.GenerateToken(claims, u.accessToken)
 	if err != nil {
-		return "", errors.New("invalid token")
+		return "", "", "", errors.New("failed to generate token")
 	}
 
-	accessKey := os.Getenv("JWT_ACCESS_SECRET")
-	tokenString, err := jwt.GenerateToken(claims.UserId, accessKey, time.Hour)
+	refreshToken, refreshId, err := jwt.GenerateToken(claims, u.refreshToken)
 	if err != nil {
-		return "", err
+		return "", "", "", errors.New("failed to generate token")
 	}
 
-	return tokenString, nil
+	token := domain.RefreshToken{
+		Id:        refreshId,
+		UserId:    session.UserId,
+		FamilyId:  session.Id,
+		ExpiresAt: time.Now().Add(u.refreshToken.Expiry),
+	}
+
+	if err := u.refreshTokenRepo.Create(&token, ctx); err != nil {
+		return "", "", "", fmt.Errorf("failed to store refresh token, error: %w", err)
+	}
+
+	return accessToken, refreshToken, refreshId, nil
+}
+
+func (u *userUsecase) ListSessions(userId int, ctx context.Context) ([]domain.Session, error) {
+	return u.sessionRepo.ListActiveByUser(userId, ctx)
+}
+
+func (u *userUsecase) RevokeSession(userId int, sessionId string, ctx context.Context) error {
+	session, err := u.sessionRepo.GetById(sessionId, ctx)
+	if err != nil || session.UserId != userId {
+		return errors.New("session not found")
+	}
+
+	if err := u.sessionRepo.Revoke(session.Id, ctx); err != nil {
+		return err
+	}
+
+	return u.refreshTokenRepo.RevokeFamily(session.Id, ctx)
+}
+
+func (u *userUsecase) RevokeAllSessions(userId int, ctx context.Context) error {
+	if err := u.sessionRepo.RevokeAllByUser(userId, ctx); err != nil {
+		return err
+	}
+
+	return u.refreshTokenRepo.RevokeByUser(userId, ctx)
 }
 
 func (u *userUsecase) GetProfile(userId int, ctx context.Context) (*domain.User, error) {
@@ -115,25 +564,13 @@ func (u *userUsecase) GetProfile(userId int, ctx context.Context) (*domain.User,
 }
 
 func (u *userUsecase) UpdateProfile(userId int, input domain.UpdateProfileRequest, ctx context.Context) (*domain.User, error) {
-	if input.Password == "" && input.Username == "" {
+	if input.Username == "" {
 		return nil, errors.New("no field to update")
 	}
 
-	if input.Password != "" {
-		if err := utils.ValidatePassword(input.Password); err != nil {
-			return nil, err
-		}
-		hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return nil, err
-		}
-		input.Password = string(hash)
-	}
-
 	var user domain.User
 
 	user.Id = userId
-	user.Password = input.Password
 	user.Username = input.Username
 
 	if err := u.userRepo.Update(&user, ctx); err != nil {
@@ -148,62 +585,256 @@ func (u *userUsecase) UpdateProfile(userId int, input domain.UpdateProfileReques
 	return updateUser, nil
 }
 
+// ChangePassword replaces the password of a signed-in user. Every token
+// issued before the change is revoked, and a new session is started for the
+// caller so they stay signed in.
+func (u *userUsecase) ChangePassword(userId int, input domain.ChangePasswordRequest, ctx context.Context) (string, string, error) {
+	user, err := u.userRepo.GetById(userId)
+	if err != nil {
+		return "", "", errors.New("user not found")
+	}
+
+	// The current password is guessable through this endpoint too, so it
+	// shares the login backoff and lockout.
+	if err := u.checkLoginThrottle(userId, input.IPAddress, ctx); err != nil {
+		return "", "", err
+	}
+
+	if err := u.hasher.Compare(user.Password, input.CurrentPassword); err != nil {
+		if err := u.recordLoginFailure(user, input.IPAddress, ctx); err != nil {
+			return "", "", err
+		}
+		return "", "", errors.New("wrong password")
+	}
+
+	if err := u.userRepo.ResetLoginFailures(userId, input.IPAddress, ctx); err != nil {
+		return "", "", err
+	}
+
+	if err := u.checkPassword(input.NewPassword, user.Username, user.Email, user.FullName); err != nil {
+		return "", "", err
+	}
+
+	hash, err := u.hasher.Hash(input.NewPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := u.setPassword(userId, hash, ctx); err != nil {
+		return "", "", err
+	}
+
+	session := domain.Session{
+		UserId:    userId,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	return u.StartSession(&session, ctx)
+}
+
+// setPassword stores the new hash, which bumps the token version, and ends
+// every session so the revocation also shows up in the session list.
+func (u *userUsecase) setPassword(userId int, hash string, ctx context.Context) error {
+	if err := u.userRepo.UpdatePassword(userId, hash, ctx); err != nil {
+		return fmt.Errorf("failed to update password, error: %w", err)
+	}
+
+	return u.RevokeAllSessions(userId, ctx)
+}
+
 func (u *userUsecase) ForgotPassword(input domain.ForgotPasswordRequest, ctx context.Context) error {
 	var user domain.User
 	user.Email = input.Email
 
 	if err := u.userRepo.GetByEmail(&user, ctx); err != nil {
-		return errors.New("user not found")
+		return hideAccountError(u.hideAccounts, errors.New("user not found"))
 	}
 
-	randNum := rand.Intn(1000000)
-	otp := fmt.Sprintf("%06d", randNum)
-	exp := time.Now().Add(5 * time.Minute)
+	// With enumeration protection on, the reset is stored and sent after the
+	// response, so a known address answers as fast as an unknown one. Its
+	// errors would be hidden anyway. The request context is not kept, since
+	// gin reuses it once the response is written.
+	if u.hideAccounts {
+		go u.sendPasswordReset(&user, input, context.Background())
+		return nil
+	}
 
-	if err := u.userRepo.SaveOTP(input.Email, otp, exp, ctx); err != nil {
+	return u.sendPasswordReset(&user, input, ctx)
+}
+
+func (u *userUsecase) sendPasswordReset(user *domain.User, input domain.ForgotPasswordRequest, ctx context.Context) error {
+	if existing, err := u.userRepo.FindPasswordReset(input.Email, ctx); err == nil && time.Since(existing.CreatedAt) < otpResendCooldown {
+		return errors.New("please wait before requesting another code")
+	}
+
+	method := input.Method
+	if method == "" {
+		method = u.passwordReset.Method
+	}
+
+	var code, template string
+	var expiry time.Duration
+	var data map[string]any
+	var err error
+
+	switch method {
+	case domain.ResetMethodLink:
+		code, err = utils.RandomToken(32)
+		template, expiry = mailer.TemplatePasswordResetLink, u.passwordReset.LinkTTL
+		data = map[string]any{"Link": u.passwordReset.LinkURL + "?token=" + url.QueryEscape(code)}
+	default:
+		method = domain.ResetMethodOTP
+		code, err = utils.RandomDigits(6)
+		template, expiry = mailer.TemplatePasswordResetOTP, otpExpiry
+		data = map[string]any{"OTP": code}
+	}
+	if err != nil {
+		return errors.New("failed to generate reset code")
+	}
+
+	now := time.Now()
+	reset := domain.PasswordReset{
+		Email:     input.Email,
+		Method:    method,
+		CodeHash:  utils.HashTokenWithKey(u.passwordReset.Secret, code),
+		ExpiresAt: now.Add(expiry),
+		CreatedAt: now,
+	}
+
+	if err := u.userRepo.SavePasswordReset(&reset, ctx); err != nil {
 		return err
 	}
 
-	fmt.Println("The OTP code for", input.Email, "is", otp)
-	return nil
-}
+	data["Name"] = user.FullName
+	data["ExpiresIn"] = humanDuration(expiry)
 
-func (u *userUsecase) ResetPassword(input domain.ResetPasswordRequest, ctx context.Context) error {
-	otp, exp, err := u.userRepo.FindOTP(input.Email, ctx)
+	msg, err := mailer.Render(template, input.Email, data)
 	if err != nil {
 		return err
 	}
 
-	if otp != input.OTP {
-		return errors.New("The OTP code is invalid")
+	if err := u.mailer.Send(msg, ctx); err != nil {
+		return fmt.Errorf("failed to send email, error: %w", err)
 	}
 
-	if time.Now().After(exp) {
-		return errors.New("The OTP has been expired")
+	return nil
+}
+
+// ResetPassword accepts either an emailed OTP together with the address it
+// was sent to, or the token from a reset link.
+func (u *userUsecase) ResetPassword(input domain.ResetPasswordRequest, ctx context.Context) error {
+	var reset *domain.PasswordReset
+	var err error
+
+	switch {
+	case input.Token != "":
+		reset, err = u.checkResetToken(input.Token, ctx)
+	case input.Email != "" && input.OTP != "":
+		reset, err = u.checkResetOTP(input.Email, input.OTP, ctx)
+	default:
+		return errors.New("either token or email and otp_code are required")
+	}
+	if err != nil {
+		return err
 	}
 
 	var user domain.User
-	user.Email = input.Email
+	user.Email = reset.Email
 	if err := u.userRepo.GetByEmail(&user, ctx); err != nil {
 		return err
 	}
 
-	if err := utils.ValidatePassword(input.NewPassword); err != nil {
+	// Checked before the code is consumed, so a rejected password can be
+	// retried with the same code.
+	if err := u.checkPassword(input.NewPassword, user.Username, user.Email, user.FullName); err != nil {
 		return err
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	// Deleting the code is what consumes it; only one request can win.
+	deleted, err := u.userRepo.DeletePasswordReset(reset.Email, reset.CodeHash, ctx)
 	if err != nil {
 		return err
 	}
+	if !deleted {
+		return errors.New("The reset code is invalid")
+	}
 
-	user.Password = string(hash)
-
-	if err := u.userRepo.Update(&user, ctx); err != nil {
+	hash, err := u.hasher.Hash(input.NewPassword)
+	if err != nil {
 		return err
 	}
 
-	u.userRepo.DeleteOTP(input.Email, ctx)
+	return u.setPassword(user.Id, hash, ctx)
+}
 
-	return nil
+func humanDuration(d time.Duration) string {
+	if d < time.Minute {
+		seconds := int((d + time.Second - 1) / time.Second)
+		if seconds == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", seconds)
+	}
+
+	if d > 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+
+	if d >= time.Hour && d%time.Hour == 0 {
+		if d == time.Hour {
+			return "1 hour"
+		}
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	}
+
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	if minutes == 1 {
+		return "1 minute"
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+func (u *userUsecase) checkResetOTP(email, otp string, ctx context.Context) (*domain.PasswordReset, error) {
+	reset, err := u.userRepo.FindPasswordReset(email, ctx)
+	if err != nil || reset.Method != domain.ResetMethodOTP {
+		return nil, errors.New("The OTP code is invalid")
+	}
+
+	if time.Now().After(reset.ExpiresAt) {
+		u.userRepo.DeletePasswordReset(email, reset.CodeHash, ctx)
+		return nil, errors.New("The OTP has been expired")
+	}
+
+	allowed, err := u.userRepo.IncrementPasswordResetAttempts(email, otpMaxAttempts, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		u.userRepo.DeletePasswordReset(email, reset.CodeHash, ctx)
+		return nil, errors.New("too many attempts, please request a new code")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(utils.HashTokenWithKey(u.passwordReset.Secret, otp)), []byte(reset.CodeHash)) != 1 {
+		if reset.Attempts+1 >= otpMaxAttempts {
+			u.userRepo.DeletePasswordReset(email, reset.CodeHash, ctx)
+		}
+		return nil, errors.New("The OTP code is invalid")
+	}
+
+	return reset, nil
+}
+
+func (u *userUsecase) checkResetToken(token string, ctx context.Context) (*domain.PasswordReset, error) {
+	reset, err := u.userRepo.FindPasswordResetByHash(utils.HashTokenWithKey(u.passwordReset.Secret, token), ctx)
+	if err != nil || reset.Method != domain.ResetMethodLink {
+		return nil, errors.New("The reset link is invalid")
+	}
+
+	if time.Now().After(reset.ExpiresAt) {
+		u.userRepo.DeletePasswordReset(reset.Email, reset.CodeHash, ctx)
+		return nil, errors.New("The reset link has expired")
+	}
+
+	return reset, nil
 }
@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/mailer"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+)
+
+func (r *stubUserRepository) FindByEmailOrUsername(email, username string) (*domain.User, error) {
+	for _, user := range r.users {
+		if user.Email == email || user.Username == username {
+			return user, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+func TestRegisterHidesTakenAccounts(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    domain.RegisterRequest
+		template string
+	}{
+		{
+			name:     "email",
+			input:    domain.RegisterRequest{FullName: "Someone", Username: "someone", Email: "test@example.com"},
+			template: mailer.TemplateAccountExists,
+		},
+		{
+			name:     "username",
+			input:    domain.RegisterRequest{FullName: "Someone", Username: "test", Email: "someone@example.com"},
+			template: mailer.TemplateUsernameTaken,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMFAFixture(t, 10)
+			f.logins.hideAccounts = true
+			f.logins.passwordPolicy = utils.DefaultPasswordPolicy()
+
+			tt.input.Password = "Tr0ubadour&Horse-Battery"
+			user, err := f.logins.Register(tt.input, context.Background())
+			if user != nil || err != nil {
+				t.Fatalf("Register = %v, %v, want the same answer as a new account", user, err)
+			}
+
+			want, err := mailer.Render(tt.template, tt.input.Email, map[string]any{"Name": "", "Username": ""})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			messages := f.mail.Messages()
+			if len(messages) != 1 || messages[0].To != tt.input.Email || messages[0].Subject != want.Subject {
+				t.Fatalf("messages = %+v, want one %q to %s", messages, want.Subject, tt.input.Email)
+			}
+		})
+	}
+}
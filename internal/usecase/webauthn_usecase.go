@@ -0,0 +1,339 @@
+package usecase
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/utils"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const webauthnCeremonyExpiry = 5 * time.Minute
+
+type webauthnUsecase struct {
+	webauthnRepo domain.WebAuthnRepository
+	userUsecase  domain.UserUsecase
+	webauthn     *webauthn.WebAuthn
+}
+
+// webauthnUser adapts a user and their stored passkeys to the interface the
+// WebAuthn library expects. The user handle is the decimal user id.
+type webauthnUser struct {
+	user        *domain.User
+	credentials []webauthn.Credential
+}
+
+func (w *webauthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(w.user.Id))
+}
+
+func (w *webauthnUser) WebAuthnName() string {
+	return w.user.Email
+}
+
+func (w *webauthnUser) WebAuthnDisplayName() string {
+	return w.user.FullName
+}
+
+func (w *webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return w.credentials
+}
+
+func NewWebAuthnUsecase(r domain.WebAuthnRepository, u domain.UserUsecase, w *webauthn.WebAuthn) domain.WebAuthnUsecase {
+	return &webauthnUsecase{
+		webauthnRepo: r,
+		userUsecase:  u,
+		webauthn:     w,
+	}
+}
+
+func (w *webauthnUsecase) BeginRegistration(userId int, ctx context.Context) (*domain.WebAuthnBeginResponse, error) {
+	user, err := w.loadUser(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options, session, err := w.webauthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin registration, error: %w", err)
+	}
+
+	token, err := w.saveCeremony(userId, domain.CeremonyRegistration, session, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.WebAuthnBeginResponse{CeremonyToken: token, Options: options}, nil
+}
+
+func (w *webauthnUsecase) FinishRegistration(userId int, input domain.WebAuthnRegisterRequest, ctx context.Context) (*domain.WebAuthnCredential, error) {
+	session, err := w.takeCeremony(input.CeremonyToken, domain.CeremonyRegistration, userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := w.loadUser(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(input.Credential)
+	if err != nil {
+		return nil, errors.New("invalid credential")
+	}
+
+	credential, err := w.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, errors.New("passkey registration failed")
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = "Passkey"
+	}
+
+	stored := domain.WebAuthnCredential{
+		Id:        base64.RawURLEncoding.EncodeToString(credential.ID),
+		UserId:    userId,
+		Name:      name,
+		Data:      data,
+		SignCount: credential.Authenticator.SignCount,
+		CreatedAt: time.Now(),
+	}
+
+	if err := w.webauthnRepo.CreateCredential(&stored, ctx); err != nil {
+		return nil, fmt.Errorf("failed to save passkey, error: %w", err)
+	}
+
+	return &stored, nil
+}
+
+func (w *webauthnUsecase) ListCredentials(userId int, ctx context.Context) ([]domain.WebAuthnCredential, error) {
+	return w.webauthnRepo.ListCredentialsByUser(userId, ctx)
+}
+
+func (w *webauthnUsecase) DeleteCredential(userId int, id string, ctx context.Context) error {
+	deleted, err := w.webauthnRepo.DeleteCredential(userId, id, ctx)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("passkey not found")
+	}
+
+	return nil
+}
+
+// BeginLogin starts a passwordless login. No user is known yet, so the
+// authenticator offers any discoverable passkey it holds for this site.
+func (w *webauthnUsecase) BeginLogin(ctx context.Context) (*domain.WebAuthnBeginResponse, error) {
+	options, session, err := w.webauthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin login, error: %w", err)
+	}
+
+	token, err := w.saveCeremony(0, domain.CeremonyLogin, session, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.WebAuthnBeginResponse{CeremonyToken: token, Options: options}, nil
+}
+
+func (w *webauthnUsecase) FinishLogin(input domain.WebAuthnLoginRequest, ctx context.Context) (*domain.LoginResult, error) {
+	session, err := w.takeCeremony(input.CeremonyToken, domain.CeremonyLogin, 0, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(input.Credential)
+	if err != nil {
+		return nil, errors.New("invalid credential")
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, errors.New("unknown user")
+		}
+
+		stored, err := w.webauthnRepo.GetCredential(base64.RawURLEncoding.EncodeToString(rawID), ctx)
+		if err != nil || stored.UserId != userId {
+			return nil, errors.New("unknown credential")
+		}
+
+		return w.loadUser(userId, ctx)
+	}
+
+	found, credential, err := w.webauthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return nil, errors.New("passkey login failed")
+	}
+
+	user := found.(*webauthnUser)
+	if err := w.recordUse(credential, ctx); err != nil {
+		return nil, err
+	}
+
+	sessionRecord := domain.Session{
+		UserId:    user.user.Id,
+		UserAgent: input.UserAgent,
+		IPAddress: input.IPAddress,
+	}
+
+	// The passkey was verified by the user, so it counts as both factors.
+	return w.userUsecase.CompleteMultiFactorLogin(user.user, &sessionRecord, ctx)
+}
+
+func (w *webauthnUsecase) BeginSecondFactor(userId int, ctx context.Context) (*domain.WebAuthnBeginResponse, error) {
+	user, err := w.loadUser(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(user.credentials) == 0 {
+		return nil, errors.New("no passkeys registered")
+	}
+
+	options, session, err := w.webauthn.BeginLogin(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin login, error: %w", err)
+	}
+
+	token, err := w.saveCeremony(userId, domain.CeremonySecondFactor, session, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.WebAuthnBeginResponse{CeremonyToken: token, Options: options}, nil
+}
+
+func (w *webauthnUsecase) VerifySecondFactor(userId int, ceremonyToken string, credential json.RawMessage, ctx context.Context) error {
+	session, err := w.takeCeremony(ceremonyToken, domain.CeremonySecondFactor, userId, ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := w.loadUser(userId, ctx)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credential)
+	if err != nil {
+		return errors.New("invalid credential")
+	}
+
+	validated, err := w.webauthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		return errors.New("invalid passkey")
+	}
+
+	return w.recordUse(validated, ctx)
+}
+
+func (w *webauthnUsecase) loadUser(userId int, ctx context.Context) (*webauthnUser, error) {
+	user, err := w.userUsecase.GetProfile(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := w.webauthnRepo.ListCredentialsByUser(userId, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, s := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(s.Data, &credential); err != nil {
+			return nil, fmt.Errorf("failed to decode passkey, error: %w", err)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webauthnUser{user: user, credentials: credentials}, nil
+}
+
+// recordUse stores the new sign counter. A counter that did not advance means
+// the authenticator may have been cloned, so the login is refused.
+func (w *webauthnUsecase) recordUse(credential *webauthn.Credential, ctx context.Context) error {
+	if credential.Authenticator.CloneWarning {
+		return errors.New("passkey sign counter did not increase")
+	}
+
+	data, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	stored := domain.WebAuthnCredential{
+		Id:        base64.RawURLEncoding.EncodeToString(credential.ID),
+		Data:      data,
+		SignCount: credential.Authenticator.SignCount,
+	}
+
+	return w.webauthnRepo.UpdateCredential(&stored, ctx)
+}
+
+func (w *webauthnUsecase) saveCeremony(userId int, kind string, session *webauthn.SessionData, ctx context.Context) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return "", errors.New("failed to generate token")
+	}
+
+	ceremony := domain.WebAuthnCeremony{
+		TokenHash: utils.HashToken(token),
+		UserId:    userId,
+		Kind:      kind,
+		Data:      data,
+		ExpiresAt: time.Now().Add(webauthnCeremonyExpiry),
+	}
+
+	if err := w.webauthnRepo.SaveCeremony(&ceremony, ctx); err != nil {
+		return "", fmt.Errorf("failed to save ceremony, error: %w", err)
+	}
+
+	return token, nil
+}
+
+func (w *webauthnUsecase) takeCeremony(token, kind string, userId int, ctx context.Context) (*webauthn.SessionData, error) {
+	ceremony, err := w.webauthnRepo.TakeCeremony(utils.HashToken(token), ctx)
+	if err != nil {
+		return nil, errors.New("invalid or expired ceremony")
+	}
+
+	if ceremony.Kind != kind || ceremony.UserId != userId || time.Now().After(ceremony.ExpiresAt) {
+		return nil, errors.New("invalid or expired ceremony")
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode ceremony, error: %w", err)
+	}
+
+	return &session, nil
+}
@@ -0,0 +1,527 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Hdeee1/go-register-login-profile/internal/domain"
+	"github.com/Hdeee1/go-register-login-profile/pkg/jwt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:5173"
+)
+
+// softAuthenticator is a software passkey: one ES256 key pair on a
+// discoverable credential, with "none" attestation and user verification
+// always performed.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, userId int) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+
+	return &softAuthenticator{
+		key:          key,
+		credentialId: credentialId,
+		userHandle:   []byte(strconv.Itoa(userId)),
+	}
+}
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+func (a *softAuthenticator) authData(flags byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(testRPID))
+
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func clientData(t *testing.T, kind string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"type":      kind,
+		"challenge": challenge.String(),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// register answers the options from BeginRegistration.
+func (a *softAuthenticator) register(t *testing.T, options any) json.RawMessage {
+	t.Helper()
+
+	creation, ok := options.(*protocol.CredentialCreation)
+	if !ok {
+		t.Fatalf("options are %T, want *protocol.CredentialCreation", options)
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(flagUserPresent | flagUserVerified | flagAttestedData)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialId)))
+	authData = append(authData, a.credentialId...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    encode(clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encode(attestation),
+	})
+}
+
+// assert answers the options from BeginLogin or BeginSecondFactor, moving
+// the sign counter on first.
+func (a *softAuthenticator) assert(t *testing.T, options any) json.RawMessage {
+	t.Helper()
+
+	assertion, ok := options.(*protocol.CredentialAssertion)
+	if !ok {
+		t.Fatalf("options are %T, want *protocol.CredentialAssertion", options)
+	}
+
+	a.signCount++
+
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	client := clientData(t, "webauthn.get", assertion.Response.Challenge)
+	clientHash := sha256.Sum256(client)
+
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.credential(t, map[string]string{
+		"clientDataJSON":    encode(client),
+		"authenticatorData": encode(authData),
+		"signature":         encode(signature),
+		"userHandle":        encode(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]string) json.RawMessage {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]any{
+		"id":       encode(a.credentialId),
+		"rawId":    encode(a.credentialId),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+type memoryWebAuthnRepository struct {
+	credentials map[string]domain.WebAuthnCredential
+	ceremonies  map[string]domain.WebAuthnCeremony
+}
+
+func newMemoryWebAuthnRepository() *memoryWebAuthnRepository {
+	return &memoryWebAuthnRepository{
+		credentials: map[string]domain.WebAuthnCredential{},
+		ceremonies:  map[string]domain.WebAuthnCeremony{},
+	}
+}
+
+func (r *memoryWebAuthnRepository) CreateCredential(credential *domain.WebAuthnCredential, ctx context.Context) error {
+	r.credentials[credential.Id] = *credential
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) GetCredential(id string, ctx context.Context) (*domain.WebAuthnCredential, error) {
+	credential, found := r.credentials[id]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	return &credential, nil
+}
+
+func (r *memoryWebAuthnRepository) ListCredentialsByUser(userId int, ctx context.Context) ([]domain.WebAuthnCredential, error) {
+	credentials := []domain.WebAuthnCredential{}
+	for _, credential := range r.credentials {
+		if credential.UserId == userId {
+			credentials = append(credentials, credential)
+		}
+	}
+
+	return credentials, nil
+}
+
+func (r *memoryWebAuthnRepository) UpdateCredential(credential *domain.WebAuthnCredential, ctx context.Context) error {
+	stored, found := r.credentials[credential.Id]
+	if !found {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	stored.Data = credential.Data
+	stored.SignCount = credential.SignCount
+	stored.LastUsedAt = &now
+	r.credentials[credential.Id] = stored
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) DeleteCredential(userId int, id string, ctx context.Context) (bool, error) {
+	if credential, found := r.credentials[id]; !found || credential.UserId != userId {
+		return false, nil
+	}
+
+	delete(r.credentials, id)
+	return true, nil
+}
+
+func (r *memoryWebAuthnRepository) SaveCeremony(ceremony *domain.WebAuthnCeremony, ctx context.Context) error {
+	r.ceremonies[ceremony.TokenHash] = *ceremony
+	return nil
+}
+
+func (r *memoryWebAuthnRepository) TakeCeremony(tokenHash string, ctx context.Context) (*domain.WebAuthnCeremony, error) {
+	ceremony, found := r.ceremonies[tokenHash]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	delete(r.ceremonies, tokenHash)
+	return &ceremony, nil
+}
+
+// The stubs below implement only what a login touches; anything else
+// panics on the nil embedded interface.
+
+type stubUserRepository struct {
+	domain.UserRepository
+	users    map[int]*domain.User
+	failures map[loginFailureKey]*storedLoginFailure
+	resets   map[string]domain.PasswordReset
+}
+
+func (r *stubUserRepository) GetById(id int) (*domain.User, error) {
+	user, found := r.users[id]
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (r *stubUserRepository) GetByEmail(user *domain.User, ctx context.Context) error {
+	for _, stored := range r.users {
+		if stored.Email == user.Email {
+			*user = *stored
+			return nil
+		}
+	}
+
+	return sql.ErrNoRows
+}
+
+func (r *stubUserRepository) GetTokenVersion(userId int, ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+type stubSessionRepository struct {
+	domain.SessionRepository
+	sessions []domain.Session
+}
+
+func (r *stubSessionRepository) Create(session *domain.Session, ctx context.Context) error {
+	r.sessions = append(r.sessions, *session)
+	return nil
+}
+
+func (r *stubSessionRepository) RevokeAllByUser(userId int, ctx context.Context) error {
+	sessions := []domain.Session{}
+	for _, session := range r.sessions {
+		if session.UserId != userId {
+			sessions = append(sessions, session)
+		}
+	}
+
+	r.sessions = sessions
+	return nil
+}
+
+type stubRefreshTokenRepository struct {
+	domain.RefreshTokenRepository
+}
+
+func (r *stubRefreshTokenRepository) Create(token *domain.RefreshToken, ctx context.Context) error {
+	return nil
+}
+
+func (r *stubRefreshTokenRepository) RevokeByUser(userId int, ctx context.Context) error {
+	return nil
+}
+
+type passkeyFixture struct {
+	usecase  domain.WebAuthnUsecase
+	repo     *memoryWebAuthnRepository
+	sessions *stubSessionRepository
+	users    map[int]*domain.User
+}
+
+func newPasskeyFixture(t *testing.T, verificationPolicy string) *passkeyFixture {
+	t.Helper()
+
+	relyingParty, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Test",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	users := map[int]*domain.User{
+		1: {Id: 1, FullName: "Verified User", Username: "verified", Email: "verified@example.com", EmailVerifiedAt: &now},
+		2: {Id: 2, FullName: "Unverified User", Username: "unverified", Email: "unverified@example.com"},
+	}
+
+	userRepo := &stubUserRepository{users: users}
+	sessions := &stubSessionRepository{}
+	tokenConfig := func(kind jwt.TokenType) jwt.TokenConfig {
+		return jwt.TokenConfig{
+			Keys:     jwt.NewHMACKeySet("test secret"),
+			Type:     kind,
+			Issuer:   "test",
+			Audience: []string{"test"},
+			Expiry:   time.Hour,
+		}
+	}
+
+	logins := &userUsecase{
+		userRepo:         userRepo,
+		refreshTokenRepo: &stubRefreshTokenRepository{},
+		sessionRepo:      sessions,
+		verification:     NewEmailVerificationUsecase(nil, userRepo, nil, verificationPolicy, "", false),
+		accessToken:      tokenConfig(jwt.AccessToken),
+		refreshToken:     tokenConfig(jwt.RefreshToken),
+	}
+
+	repo := newMemoryWebAuthnRepository()
+
+	return &passkeyFixture{
+		usecase:  NewWebAuthnUsecase(repo, logins, relyingParty),
+		repo:     repo,
+		sessions: sessions,
+		users:    users,
+	}
+}
+
+func (f *passkeyFixture) register(t *testing.T, userId int) *softAuthenticator {
+	t.Helper()
+	ctx := context.Background()
+
+	authenticator := newSoftAuthenticator(t, userId)
+
+	begin, err := f.usecase.BeginRegistration(userId, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := f.usecase.FinishRegistration(userId, domain.WebAuthnRegisterRequest{
+		CeremonyToken: begin.CeremonyToken,
+		Name:          "Laptop",
+		Credential:    authenticator.register(t, begin.Options),
+	}, ctx)
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	if stored.UserId != userId || stored.Name != "Laptop" {
+		t.Fatalf("stored credential = %+v", stored)
+	}
+
+	return authenticator
+}
+
+func (f *passkeyFixture) login(t *testing.T, authenticator *softAuthenticator) (*domain.LoginResult, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	begin, err := f.usecase.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f.usecase.FinishLogin(domain.WebAuthnLoginRequest{
+		CeremonyToken: begin.CeremonyToken,
+		Credential:    authenticator.assert(t, begin.Options),
+	}, ctx)
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	f := newPasskeyFixture(t, domain.EmailVerificationBlockLogin)
+	authenticator := f.register(t, 1)
+
+	for i := 1; i <= 2; i++ {
+		result, err := f.login(t, authenticator)
+		if err != nil {
+			t.Fatalf("login %d: %v", i, err)
+		}
+		if result.User.Id != 1 || result.AccessToken == "" || result.RefreshToken == "" || result.MFARequired {
+			t.Fatalf("login %d: result = %+v", i, result)
+		}
+
+		stored := f.repo.credentials[encode(authenticator.credentialId)]
+		if stored.SignCount != authenticator.signCount {
+			t.Fatalf("login %d: stored sign count = %d, want %d", i, stored.SignCount, authenticator.signCount)
+		}
+	}
+
+	if len(f.sessions.sessions) != 2 {
+		t.Fatalf("%d sessions started, want 2", len(f.sessions.sessions))
+	}
+}
+
+func TestPasskeyLoginRejectsReplayedCounter(t *testing.T) {
+	f := newPasskeyFixture(t, domain.EmailVerificationNone)
+	authenticator := f.register(t, 1)
+
+	if _, err := f.login(t, authenticator); err != nil {
+		t.Fatal(err)
+	}
+
+	// A clone of the authenticator would sign with the same counter again.
+	authenticator.signCount--
+	if _, err := f.login(t, authenticator); err == nil {
+		t.Fatal("login with a counter that did not advance succeeded")
+	}
+}
+
+func TestPasskeyLoginCeremonyIsSingleUse(t *testing.T) {
+	f := newPasskeyFixture(t, domain.EmailVerificationNone)
+	authenticator := f.register(t, 1)
+	ctx := context.Background()
+
+	begin, err := f.usecase.BeginLogin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := domain.WebAuthnLoginRequest{
+		CeremonyToken: begin.CeremonyToken,
+		Credential:    authenticator.assert(t, begin.Options),
+	}
+	if _, err := f.usecase.FinishLogin(input, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	input.Credential = authenticator.assert(t, begin.Options)
+	if _, err := f.usecase.FinishLogin(input, ctx); err == nil {
+		t.Fatal("ceremony was accepted twice")
+	}
+}
+
+func TestPasskeyLoginRespectsEmailVerificationPolicy(t *testing.T) {
+	f := newPasskeyFixture(t, domain.EmailVerificationBlockLogin)
+	authenticator := f.register(t, 2)
+
+	if _, err := f.login(t, authenticator); err == nil {
+		t.Fatal("unverified account logged in with a passkey under block_login")
+	}
+	if len(f.sessions.sessions) != 0 {
+		t.Fatalf("%d sessions started, want 0", len(f.sessions.sessions))
+	}
+
+	now := time.Now()
+	f.users[2].EmailVerifiedAt = &now
+
+	if _, err := f.login(t, authenticator); err != nil {
+		t.Fatalf("login after verifying: %v", err)
+	}
+}
+
+func TestPasskeySecondFactor(t *testing.T) {
+	f := newPasskeyFixture(t, domain.EmailVerificationNone)
+	authenticator := f.register(t, 1)
+	ctx := context.Background()
+
+	begin, err := f.usecase.BeginSecondFactor(1, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.usecase.VerifySecondFactor(1, begin.CeremonyToken, authenticator.assert(t, begin.Options), ctx); err != nil {
+		t.Fatalf("VerifySecondFactor: %v", err)
+	}
+
+	// A ceremony started for one user cannot be finished as another.
+	begin, err = f.usecase.BeginSecondFactor(1, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.usecase.VerifySecondFactor(2, begin.CeremonyToken, authenticator.assert(t, begin.Options), ctx); err == nil {
+		t.Fatal("second factor ceremony accepted for another user")
+	}
+
+	if _, err := f.usecase.BeginSecondFactor(2, ctx); err == nil {
+		t.Fatal("second factor started for a user without passkeys")
+	}
+}
@@ -1,31 +1,69 @@
 package jwt
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+type Blacklist interface {
+	Add(tokenId string, expiresAt time.Time, ctx context.Context) error
+	IsBlacklisted(tokenId string, ctx context.Context) (bool, error)
+}
+
 type TokenBlacklist struct {
 	mu *sync.Mutex
 	tkn map[string]time.Time
+	stop chan struct{}
 }
 
-func NewTokenBlacklist() *TokenBlacklist {
-	return &TokenBlacklist{
+func NewTokenBlacklist(sweepInterval time.Duration) *TokenBlacklist {
+	bl := &TokenBlacklist{
 		mu: &sync.Mutex{},
 		tkn: make(map[string]time.Time),
+		stop: make(chan struct{}),
 	}
+
+	go bl.sweep(sweepInterval)
+
+	return bl
 }
 
-func (bl *TokenBlacklist) AddTokenBlacklist(token string, expiresAt time.Time) {
+func (bl *TokenBlacklist) Add(tokenId string, expiresAt time.Time, ctx context.Context) error {
 	bl.mu.Lock()
-	bl.tkn[token] = expiresAt
+	bl.tkn[tokenId] = expiresAt
 	bl.mu.Unlock()
+	return nil
 }
 
-func (bl *TokenBlacklist) IsBlacklisted(token string) bool {
+func (bl *TokenBlacklist) IsBlacklisted(tokenId string, ctx context.Context) (bool, error) {
 	bl.mu.Lock()
 	defer bl.mu.Unlock()
-	_, exist := bl.tkn[token]
-	return  exist
-}
\ No newline at end of file
+	expiresAt, exist := bl.tkn[tokenId]
+	return exist && time.Now().Before(expiresAt), nil
+}
+
+func (bl *TokenBlacklist) Close() {
+	close(bl.stop)
+}
+
+func (bl *TokenBlacklist) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			bl.mu.Lock()
+			for tokenId, expiresAt := range bl.tkn {
+				if now.After(expiresAt) {
+					delete(bl.tkn, tokenId)
+				}
+			}
+			bl.mu.Unlock()
+		case <-bl.stop:
+			return
+		}
+	}
+}
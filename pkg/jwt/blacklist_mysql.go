@@ -0,0 +1,62 @@
+package jwt
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+type MySQLBlacklist struct {
+	db *sql.DB
+	stop chan struct{}
+}
+
+func NewMySQLBlacklist(db *sql.DB, sweepInterval time.Duration) *MySQLBlacklist {
+	bl := &MySQLBlacklist{
+		db: db,
+		stop: make(chan struct{}),
+	}
+
+	go bl.sweep(sweepInterval)
+
+	return bl
+}
+
+func (bl *MySQLBlacklist) Add(tokenId string, expiresAt time.Time, ctx context.Context) error {
+	query := "INSERT INTO token_blacklist (jti, expires_at) VALUES (?, ?) ON DUPLICATE KEY UPDATE expires_at = ?"
+	_, err := bl.db.ExecContext(ctx, query, tokenId, expiresAt, expiresAt)
+	return err
+}
+
+func (bl *MySQLBlacklist) IsBlacklisted(tokenId string, ctx context.Context) (bool, error) {
+	query := "SELECT COUNT(*) FROM token_blacklist WHERE jti = ? AND expires_at > ?"
+	row := bl.db.QueryRowContext(ctx, query, tokenId, time.Now())
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (bl *MySQLBlacklist) Close() {
+	close(bl.stop)
+}
+
+func (bl *MySQLBlacklist) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := bl.db.Exec("DELETE FROM token_blacklist WHERE expires_at <= ?", time.Now()); err != nil {
+				log.Printf("Failed to sweep token blacklist. Error: %s", err.Error())
+			}
+		case <-bl.stop:
+			return
+		}
+	}
+}
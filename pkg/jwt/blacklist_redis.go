@@ -0,0 +1,36 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisBlacklistPrefix = "blacklist:"
+
+type RedisBlacklist struct {
+	client *redis.Client
+}
+
+func NewRedisBlacklist(client *redis.Client) *RedisBlacklist {
+	return &RedisBlacklist{client: client}
+}
+
+func (bl *RedisBlacklist) Add(tokenId string, expiresAt time.Time, ctx context.Context) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return bl.client.Set(ctx, redisBlacklistPrefix+tokenId, 1, ttl).Err()
+}
+
+func (bl *RedisBlacklist) IsBlacklisted(tokenId string, ctx context.Context) (bool, error) {
+	count, err := bl.client.Exists(ctx, redisBlacklistPrefix+tokenId).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -1,43 +1,130 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
 type CustomClaims struct {
 	UserId  int		`json:"id"`
+	SessionId string	`json:"sid,omitempty"`
+	Type	TokenType	`json:"typ"`
+	ClientId string	`json:"client_id,omitempty"`
+	Scope	string	`json:"scope,omitempty"`
+	TokenVersion int	`json:"tv,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userId int, secretKey string, expiry time.Duration) (string, error) {
-	claims := CustomClaims{
-		UserId: userId,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
-			IssuedAt: jwt.NewNumericDate(time.Now()),
-		},
+// TokenConfig describes one kind of token: how it is signed, who issues it,
+// who it is meant for and how long it lives. The same config is used to
+// mint tokens and to decide whether a presented token is acceptable.
+type TokenConfig struct {
+	Keys     *KeySet
+	Type     TokenType
+	Issuer   string
+	Audience []string
+	Expiry   time.Duration
+}
+
+// IsServiceAccount reports whether the token was issued to a client acting
+// on its own behalf rather than to a user.
+func (c *CustomClaims) IsServiceAccount() bool {
+	return c.UserId == 0 && c.ClientId != ""
+}
+
+func (c *CustomClaims) Validate() error {
+	if c.ID == "" {
+		return errors.New("token has no jti")
+	}
+
+	if c.Subject != c.subject() {
+		return errors.New("token subject does not match principal")
+	}
+
+	return nil
+}
+
+func (c *CustomClaims) subject() string {
+	if c.IsServiceAccount() {
+		return c.ClientId
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return strconv.Itoa(c.UserId)
+}
 
-	tokenString, err := token.SignedString([]byte(secretKey))
-	if err != nil {
+func NewTokenId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 
-	return tokenString, err
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateToken signs claims as a token of cfg.Type. Only the user, session,
+// client, scope and token version fields of claims are taken from the
+// caller; the registered claims are always filled from cfg.
+func GenerateToken(claims CustomClaims, cfg TokenConfig) (string, string, error) {
+	key, err := cfg.Keys.Active()
+	if err != nil {
+		return "", "", err
+	}
+
+	tokenId, err := NewTokenId()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	claims.Type = cfg.Type
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID: tokenId,
+		Issuer: cfg.Issuer,
+		Subject: claims.subject(),
+		Audience: cfg.Audience,
+		ExpiresAt: jwt.NewNumericDate(now.Add(cfg.Expiry)),
+		NotBefore: jwt.NewNumericDate(now),
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Id
+
+	tokenString, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return tokenString, tokenId, nil
 }
 
-func ValidateToken(tokenString, secretKey string) (*CustomClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secretKey), nil
-	})
+func ValidateToken(tokenString string, cfg TokenConfig) (*CustomClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	if len(cfg.Audience) > 0 {
+		options = append(options, jwt.WithAudience(cfg.Audience...))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, cfg.Keys.Keyfunc, options...)
 
 	if err != nil {
 		return nil, err
@@ -48,5 +135,68 @@ func ValidateToken(tokenString, secretKey string) (*CustomClaims, error) {
 		return nil, errors.New("Invalid token")
 	}
 
+	if claims.Type != cfg.Type {
+		return nil, errors.New("unexpected token type")
+	}
+
+	return claims, nil
+}
+
+type IDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// GenerateIDToken signs an OpenID Connect ID token for clientId. Profile
+// and email claims are taken from claims; the registered claims are filled
+// from cfg. The signing key must be asymmetric, so relying parties can
+// verify the token against the JWKS.
+func GenerateIDToken(claims IDTokenClaims, userId int, clientId string, cfg TokenConfig) (string, error) {
+	key, err := cfg.Keys.Active()
+	if err != nil {
+		return "", err
+	}
+	if key.PublicKey() == nil {
+		return "", errors.New("id tokens need an asymmetric signing key")
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer: cfg.Issuer,
+		Subject: strconv.Itoa(userId),
+		Audience: jwt.ClaimStrings{clientId},
+		ExpiresAt: jwt.NewNumericDate(now.Add(cfg.Expiry)),
+		IssuedAt: jwt.NewNumericDate(now),
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Id
+
+	return token.SignedString(key.signKey)
+}
+
+func ValidateIDToken(tokenString, clientId, nonce string, cfg TokenConfig) (*IDTokenClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &IDTokenClaims{}, cfg.Keys.Keyfunc,
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(clientId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*IDTokenClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+
+	if claims.Nonce != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+
 	return claims, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,219 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Key struct {
+	Id        string
+	Method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+type KeySet struct {
+	mu     *sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+func NewKeySet() *KeySet {
+	return &KeySet{
+		mu:   &sync.RWMutex{},
+		keys: make(map[string]*Key),
+	}
+}
+
+func NewHMACKey(id, secret string) *Key {
+	return &Key{
+		Id:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// NewHMACKeySet signs with secret and still accepts tokens signed with any
+// of the previous secrets. Kids are derived from the secret so rotating the
+// secret never produces a kid collision.
+func NewHMACKeySet(secret string, previous ...string) *KeySet {
+	ks := NewKeySet()
+	for _, old := range previous {
+		if old != "" {
+			ks.Add(NewHMACKey(hmacKeyId(old), old))
+		}
+	}
+
+	active := NewHMACKey(hmacKeyId(secret), secret)
+	ks.Add(active)
+	ks.SetActive(active.Id)
+	return ks
+}
+
+func hmacKeyId(secret string) string {
+	sum := sha256.Sum256([]byte("kid:" + secret))
+	return "hs-" + hex.EncodeToString(sum[:6])
+}
+
+func ParsePrivateKeyPEM(id string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM block found", id)
+	}
+
+	var privateKey any
+	var err error
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+
+	return newAsymmetricKey(id, privateKey)
+}
+
+func LoadKeyFile(id, path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePrivateKeyPEM(id, data)
+}
+
+func (ks *KeySet) Add(key *Key) {
+	ks.mu.Lock()
+	ks.keys[key.Id] = key
+	ks.mu.Unlock()
+}
+
+func (ks *KeySet) SetActive(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, exist := ks.keys[id]; !exist {
+		return fmt.Errorf("signing key %q not found", id)
+	}
+
+	ks.active = id
+	return nil
+}
+
+func (ks *KeySet) Active() (*Key, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, exist := ks.keys[ks.active]
+	if !exist {
+		return nil, errors.New("no active signing key")
+	}
+
+	return key, nil
+}
+
+func (ks *KeySet) Get(id string) (*Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, exist := ks.keys[id]
+	return key, exist
+}
+
+func (ks *KeySet) Keys() []*Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make([]*Key, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+
+	return keys
+}
+
+// Keyfunc resolves the verification key for t by its kid header and
+// refuses tokens whose alg does not match the key.
+func (ks *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key, exist := ks.Get(kid)
+	if !exist {
+		return nil, errors.New("unknown signing key")
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.verifyKey, nil
+}
+
+// Algorithms lists the algorithms of the published keys. HMAC keys are
+// left out, since no one outside this server can verify them.
+func (ks *KeySet) Algorithms() []string {
+	algs := []string{}
+	for _, key := range ks.Keys() {
+		if key.PublicKey() != nil && !slices.Contains(algs, key.Method.Alg()) {
+			algs = append(algs, key.Method.Alg())
+		}
+	}
+
+	return algs
+}
+
+func (k *Key) PublicKey() crypto.PublicKey {
+	if k.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return nil
+	}
+
+	return k.verifyKey
+}
+
+func newAsymmetricKey(id string, privateKey any) (*Key, error) {
+	switch pk := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &Key{Id: id, Method: jwt.SigningMethodRS256, signKey: pk, verifyKey: &pk.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		var method jwt.SigningMethod
+		switch pk.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("key %s: unsupported elliptic curve", id)
+		}
+		return &Key{Id: id, Method: method, signKey: pk, verifyKey: &pk.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{Id: id, Method: jwt.SigningMethodEdDSA, signKey: pk, verifyKey: pk.Public()}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported private key type %T", id, privateKey)
+	}
+}
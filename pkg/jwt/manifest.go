@@ -0,0 +1,213 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const manifestFile = "keys.json"
+
+type KeyStatus string
+
+const (
+	KeyActive  KeyStatus = "active"
+	KeyVerify  KeyStatus = "verify"
+	KeyRetired KeyStatus = "retired"
+)
+
+type ManifestEntry struct {
+	Id        string     `json:"kid"`
+	Alg       string     `json:"alg"`
+	Status    KeyStatus  `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	DemotedAt *time.Time `json:"demoted_at,omitempty"`
+	RetiredAt *time.Time `json:"retired_at,omitempty"`
+}
+
+// Manifest records every key in a keys directory and its lifecycle state.
+// Exactly one key is active (used for signing); verify keys are still
+// accepted and published; retired keys are gone for good.
+type Manifest struct {
+	Keys []ManifestEntry `json:"keys"`
+}
+
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{Keys: []ManifestEntry{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid key manifest: %w", err)
+	}
+
+	return &m, nil
+}
+
+func (m *Manifest) Write(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(dir, manifestFile))
+}
+
+func (m *Manifest) Find(id string) *ManifestEntry {
+	for i := range m.Keys {
+		if m.Keys[i].Id == id {
+			return &m.Keys[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *Manifest) Active() *ManifestEntry {
+	for i := range m.Keys {
+		if m.Keys[i].Status == KeyActive {
+			return &m.Keys[i]
+		}
+	}
+
+	return nil
+}
+
+func (m *Manifest) Promote(id string) error {
+	entry := m.Find(id)
+	if entry == nil {
+		return fmt.Errorf("key %q not found", id)
+	}
+	if entry.Status != KeyVerify {
+		return fmt.Errorf("key %q is %s, only verify keys can be promoted", id, entry.Status)
+	}
+
+	now := time.Now().UTC()
+	if current := m.Active(); current != nil {
+		current.Status = KeyVerify
+		current.DemotedAt = &now
+	}
+
+	entry.Status = KeyActive
+	entry.DemotedAt = nil
+	return nil
+}
+
+// Retire drops a key from verification once every token it signed has
+// expired, i.e. maxTokenLifetime has passed since it stopped signing.
+func (m *Manifest) Retire(id string, maxTokenLifetime time.Duration, force bool) error {
+	entry := m.Find(id)
+	if entry == nil {
+		return fmt.Errorf("key %q not found", id)
+	}
+	if entry.Status != KeyVerify {
+		return fmt.Errorf("key %q is %s, only verify keys can be retired", id, entry.Status)
+	}
+	if !force && entry.DemotedAt != nil && time.Since(*entry.DemotedAt) < maxTokenLifetime {
+		return fmt.Errorf("key %q may still have live tokens until %s", id, entry.DemotedAt.Add(maxTokenLifetime).Format(time.RFC3339))
+	}
+
+	now := time.Now().UTC()
+	entry.Status = KeyRetired
+	entry.RetiredAt = &now
+	return nil
+}
+
+// GenerateKeyFile creates a new private key for alg and writes it to
+// <dir>/<id>.pem in PKCS#8 form.
+func GenerateKeyFile(dir, id, alg string) error {
+	var privateKey any
+	var err error
+
+	switch alg {
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	return os.WriteFile(filepath.Join(dir, id+".pem"), data, 0600)
+}
+
+// LoadKeySet loads every non-retired key listed in the manifest of dir.
+func LoadKeySet(dir string) (*KeySet, error) {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	active := m.Active()
+	if active == nil {
+		return nil, fmt.Errorf("no active key in %s", filepath.Join(dir, manifestFile))
+	}
+
+	ks := NewKeySet()
+	for _, entry := range m.Keys {
+		if entry.Status == KeyRetired {
+			continue
+		}
+
+		key, err := LoadKeyFile(entry.Id, filepath.Join(dir, entry.Id+".pem"))
+		if err != nil {
+			return nil, err
+		}
+		ks.Add(key)
+	}
+
+	if err := ks.SetActive(active.Id); err != nil {
+		return nil, err
+	}
+
+	return ks, nil
+}
+
+// Reload swaps in the keys currently on disk so promotions and
+// retirements take effect without a restart.
+func (ks *KeySet) Reload(dir string) error {
+	fresh, err := LoadKeySet(dir)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.keys = fresh.keys
+	ks.active = fresh.active
+	ks.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaildirMailer writes every message into a Maildir so it can be opened
+// with a mail client during development instead of being sent.
+type MaildirMailer struct {
+	dir  string
+	from string
+}
+
+func NewMaildirMailer(dir, from string) (*MaildirMailer, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MaildirMailer{dir: dir, from: from}, nil
+}
+
+func (m *MaildirMailer) Send(msg *Message, ctx context.Context) error {
+	body, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%d.%s.%s", time.Now().UnixNano(), hex.EncodeToString(suffix), host)
+
+	tmp := filepath.Join(m.dir, "tmp", name)
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(m.dir, "new", name))
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+type job struct {
+	msg     *Message
+	attempt int
+}
+
+// Queue sends messages in the background so a slow or unavailable mail
+// server does not hold up the request. Failed deliveries are retried with
+// exponential backoff until maxAttempts is reached.
+type Queue struct {
+	mailer      Mailer
+	jobs        chan job
+	quit        chan struct{}
+	wg          sync.WaitGroup
+	maxAttempts int
+	backoff     time.Duration
+	timeout     time.Duration
+}
+
+func NewQueue(mailer Mailer, size, workers, maxAttempts int, backoff time.Duration) *Queue {
+	q := &Queue{
+		mailer:      mailer,
+		jobs:        make(chan job, size),
+		quit:        make(chan struct{}),
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		timeout:     30 * time.Second,
+	}
+
+	for range workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return q
+}
+
+func (q *Queue) Send(msg *Message, ctx context.Context) error {
+	return q.enqueue(job{msg: msg})
+}
+
+// Close stops the workers after the messages already queued have been
+// attempted once. Pending retries are dropped.
+func (q *Queue) Close() {
+	close(q.quit)
+	q.wg.Wait()
+}
+
+func (q *Queue) enqueue(j job) error {
+	select {
+	case <-q.quit:
+		return errors.New("mail queue is closed")
+	default:
+	}
+
+	select {
+	case q.jobs <- j:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case j := <-q.jobs:
+			q.deliver(j)
+		case <-q.quit:
+			for {
+				select {
+				case j := <-q.jobs:
+					q.deliver(j)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *Queue) deliver(j job) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	err := q.mailer.Send(j.msg, ctx)
+	if err == nil {
+		return
+	}
+
+	j.attempt++
+	if j.attempt >= q.maxAttempts {
+		log.Printf("Failed to send %q to %s after %d attempts. Error: %s", j.msg.Subject, j.msg.To, j.attempt, err.Error())
+		return
+	}
+
+	delay := q.backoff << (j.attempt - 1)
+	time.AfterFunc(delay, func() {
+		if err := q.enqueue(j); err != nil {
+			log.Printf("Failed to requeue %q to %s. Error: %s", j.msg.Subject, j.msg.To, err.Error())
+		}
+	})
+}
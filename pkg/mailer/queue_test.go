@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failingMailer fails the first failures sends, or every send if failures
+// is negative, and hands the rest to next.
+type failingMailer struct {
+	mu       sync.Mutex
+	failures int
+	attempts []time.Time
+	next     Mailer
+}
+
+func (m *failingMailer) Send(msg *Message, ctx context.Context) error {
+	m.mu.Lock()
+	m.attempts = append(m.attempts, time.Now())
+	fail := m.failures < 0 || len(m.attempts) <= m.failures
+	m.mu.Unlock()
+
+	if fail {
+		return errors.New("mail server unavailable")
+	}
+
+	return m.next.Send(msg, ctx)
+}
+
+func (m *failingMailer) Attempts() []time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]time.Time(nil), m.attempts...)
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testMessage(i int) *Message {
+	return &Message{To: fmt.Sprintf("user%d@example.com", i), Subject: "Hello", Text: "Hi"}
+}
+
+func TestQueueDeliversEveryMessage(t *testing.T) {
+	memory := NewMemoryMailer()
+	q := NewQueue(memory, 10, 3, 3, time.Millisecond)
+
+	for i := range 10 {
+		if err := q.Send(testMessage(i), context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.Close()
+
+	sent := map[string]bool{}
+	for _, msg := range memory.Messages() {
+		sent[msg.To] = true
+	}
+	for i := range 10 {
+		if to := testMessage(i).To; !sent[to] {
+			t.Errorf("no message sent to %s", to)
+		}
+	}
+}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	const backoff = 20 * time.Millisecond
+
+	memory := NewMemoryMailer()
+	flaky := &failingMailer{failures: 2, next: memory}
+	q := NewQueue(flaky, 10, 1, 5, backoff)
+	defer q.Close()
+
+	if err := q.Send(testMessage(1), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "delivery", func() bool { return len(memory.Messages()) == 1 })
+
+	attempts := flaky.Attempts()
+	if len(attempts) != 3 {
+		t.Fatalf("%d attempts, want 3", len(attempts))
+	}
+
+	// Each retry waits twice as long as the one before.
+	for i, want := range []time.Duration{backoff, 2 * backoff} {
+		if gap := attempts[i+1].Sub(attempts[i]); gap < want {
+			t.Errorf("retry %d came after %s, want at least %s", i+1, gap, want)
+		}
+	}
+}
+
+func TestQueueGivesUpAfterMaxAttempts(t *testing.T) {
+	broken := &failingMailer{failures: -1}
+	q := NewQueue(broken, 10, 1, 3, time.Millisecond)
+	defer q.Close()
+
+	if err := q.Send(testMessage(1), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "three attempts", func() bool { return len(broken.Attempts()) >= 3 })
+
+	// Long enough for a fourth attempt, were one scheduled.
+	time.Sleep(50 * time.Millisecond)
+	if n := len(broken.Attempts()); n != 3 {
+		t.Fatalf("%d attempts, want 3", n)
+	}
+}
+
+func TestQueueCloseDropsPendingRetries(t *testing.T) {
+	broken := &failingMailer{failures: -1}
+	q := NewQueue(broken, 10, 1, 5, time.Hour)
+
+	if err := q.Send(testMessage(1), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "first attempt", func() bool { return len(broken.Attempts()) == 1 })
+	q.Close()
+
+	if err := q.Send(testMessage(2), context.Background()); err == nil {
+		t.Fatal("Send after Close succeeded")
+	}
+	if n := len(broken.Attempts()); n != 1 {
+		t.Fatalf("%d attempts, want 1", n)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(NewMemoryMailer(), 1, 0, 1, time.Millisecond)
+	defer q.Close()
+
+	if err := q.Send(testMessage(1), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Send(testMessage(2), context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Send to a full queue returned %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+const (
+	TemplatePasswordResetOTP   = "password_reset_otp"
+	TemplatePasswordResetLink  = "password_reset_link"
+	TemplateMagicLink          = "magic_link"
+	TemplateEmailVerification  = "email_verification"
+	TemplateEmailChangeConfirm = "email_change_confirm"
+	TemplateEmailChangeNotice  = "email_change_notice"
+	TemplateEmailChangeTaken   = "email_change_taken"
+	TemplateAccountLocked      = "account_locked"
+	TemplateAccountExists      = "account_exists"
+	TemplateUsernameTaken      = "username_taken"
+)
+
+// Each message type has a .txt template defining "subject" and "text", and
+// a .html template defining "html". Files are parsed separately so the
+// block names can repeat.
+//
+//go:embed templates/*
+var templateFS embed.FS
+
+func Render(name, to string, data any) (*Message, error) {
+	text, err := template.ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	html, err := htmltemplate.ParseFS(templateFS, "templates/"+name+".html")
+	if err != nil {
+		return nil, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := text.ExecuteTemplate(&textBody, "text", data); err != nil {
+		return nil, err
+	}
+	if err := html.ExecuteTemplate(&htmlBody, "html", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		To:      to,
+		Subject: subject.String(),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
@@ -0,0 +1,144 @@
+package pwned
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// The index holds, for each of the 65536 values of the first two bytes of
+// the hash, the offset of the first line with that prefix, followed by the
+// file size. It is written next to the corpus so the full scan that builds
+// it only happens once; the corpus size and modification time in the header
+// tell when it is stale.
+
+var indexMagic = []byte("PWNDIDX1")
+
+const indexEntries = 1<<16 + 1
+
+func loadIndex(file *os.File, path string) ([]int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if index, err := readIndex(path, info); err == nil {
+		return index, nil
+	}
+
+	index, err := buildIndex(file, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	// A read-only directory only costs a rescan on the next start.
+	writeIndex(path, info, index)
+
+	return index, nil
+}
+
+func readIndex(path string, info os.FileInfo) ([]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(indexMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+
+	var size, modTime int64
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &modTime); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(magic, indexMagic) || size != info.Size() || modTime != info.ModTime().UnixNano() {
+		return nil, os.ErrNotExist
+	}
+
+	index := make([]int64, indexEntries)
+	if err := binary.Read(r, binary.BigEndian, index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func writeIndex(path string, info os.FileInfo, index []int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	w.Write(indexMagic)
+	binary.Write(w, binary.BigEndian, info.Size())
+	binary.Write(w, binary.BigEndian, info.ModTime().UnixNano())
+	binary.Write(w, binary.BigEndian, index)
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+func buildIndex(file *os.File, size int64) ([]int64, error) {
+	index := make([]int64, indexEntries)
+	next := 0
+
+	r := bufio.NewReaderSize(io.NewSectionReader(file, 0, size), 1<<20)
+	prefix := make([]byte, 2)
+	var offset int64
+
+	for {
+		line, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, ErrCorrupt
+		}
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if len(line) < hashLen {
+			return nil, ErrCorrupt
+		}
+		if _, err := hex.Decode(prefix, line[:4]); err != nil {
+			return nil, ErrCorrupt
+		}
+
+		bucket := int(prefix[0])<<8 | int(prefix[1])
+		if bucket+1 < next {
+			return nil, ErrCorrupt
+		}
+		for ; next <= bucket; next++ {
+			index[next] = offset
+		}
+
+		offset += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+	}
+
+	for ; next < indexEntries; next++ {
+		index[next] = size
+	}
+
+	return index, nil
+}
@@ -0,0 +1,185 @@
+// Package pwned looks passwords up in a local copy of the Have I Been Pwned
+// password corpus, so no password or hash prefix ever leaves the server.
+//
+// The corpus is the "ordered by hash" SHA-1 download: one line per
+// password, the upper-case hex hash, a colon and the number of times it was
+// seen, sorted by hash.
+package pwned
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Checker reports whether a password is too common to be allowed.
+type Checker interface {
+	Breached(password string) (bool, error)
+}
+
+// Corpus answers lookups with a binary search over the file on disk. The
+// index narrows the search to the lines sharing the first 16 bits of the
+// hash; see index.go.
+type Corpus struct {
+	file      *os.File
+	index     []int64
+	threshold int
+}
+
+const (
+	hashLen = 40
+
+	// scanSize is how small the binary search narrows a range before the
+	// rest is read in one go.
+	scanSize = 64 * 1024
+)
+
+var ErrCorrupt = errors.New("breached password corpus is not sorted SHA-1 lines")
+
+// Open opens the corpus at path. A password counts as breached once it has
+// been seen at least threshold times.
+func Open(path string, threshold int) (*Corpus, error) {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := loadIndex(file, path+".idx")
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to index %s, error: %w", path, err)
+	}
+
+	return &Corpus{file: file, index: index, threshold: threshold}, nil
+}
+
+func (c *Corpus) Close() error {
+	return c.file.Close()
+}
+
+func (c *Corpus) Breached(password string) (bool, error) {
+	count, err := c.Count(password)
+	if err != nil {
+		return false, err
+	}
+
+	return count >= c.threshold, nil
+}
+
+// Count returns how often password appears in the corpus, or 0.
+func (c *Corpus) Count(password string) (int, error) {
+	return c.countHash(sha1.Sum([]byte(password)))
+}
+
+func (c *Corpus) countHash(sum [sha1.Size]byte) (int, error) {
+	target := []byte(hex.EncodeToString(sum[:]))
+	target = bytes.ToUpper(target)
+
+	bucket := int(sum[0])<<8 | int(sum[1])
+	lo, hi := c.index[bucket], c.index[bucket+1]
+
+	for hi-lo > scanSize {
+		mid := lo + (hi-lo)/2
+
+		start, err := c.nextLine(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		line, err := c.lineAt(start)
+		if err != nil {
+			return 0, err
+		}
+
+		switch cmp := bytes.Compare(line[:hashLen], target); {
+		case cmp == 0:
+			_, count, ok := parseLine(line)
+			if !ok {
+				return 0, ErrCorrupt
+			}
+			return count, nil
+		case cmp < 0:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = start
+		}
+	}
+
+	buf := make([]byte, hi-lo)
+	if _, err := c.file.ReadAt(buf, lo); err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	for len(buf) > 0 {
+		line, rest, _ := bytes.Cut(buf, []byte("\n"))
+		buf = rest
+
+		hash, count, ok := parseLine(line)
+		if !ok {
+			return 0, ErrCorrupt
+		}
+		if bytes.Equal(hash, target) {
+			return count, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// nextLine returns the offset of the first line that starts after pos.
+func (c *Corpus) nextLine(pos int64) (int64, error) {
+	buf := make([]byte, 128)
+	n, err := c.file.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	i := bytes.IndexByte(buf[:n], '\n')
+	if i < 0 {
+		return 0, ErrCorrupt
+	}
+
+	return pos + int64(i) + 1, nil
+}
+
+// lineAt returns the line starting at pos, without its newline.
+func (c *Corpus) lineAt(pos int64) ([]byte, error) {
+	buf := make([]byte, 128)
+	n, err := c.file.ReadAt(buf, pos)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// The last line may have no newline.
+	line, _, found := bytes.Cut(buf[:n], []byte("\n"))
+	if (!found && n == len(buf)) || len(line) < hashLen {
+		return nil, ErrCorrupt
+	}
+
+	return line, nil
+}
+
+func parseLine(line []byte) ([]byte, int, bool) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+
+	hash, count, found := bytes.Cut(line, []byte(":"))
+	if !found || len(hash) != hashLen {
+		return nil, 0, false
+	}
+
+	n, err := strconv.Atoi(string(count))
+	if err != nil {
+		return nil, 0, false
+	}
+
+	return hash, n, true
+}
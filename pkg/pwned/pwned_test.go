@@ -0,0 +1,146 @@
+package pwned
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeCorpus writes hashes as a sorted corpus file, giving each hash the
+// count i+1 for its position i in the sorted order.
+func writeCorpus(t *testing.T, hashes [][sha1.Size]byte) string {
+	t.Helper()
+
+	slices.SortFunc(hashes, func(a, b [sha1.Size]byte) int {
+		return bytes.Compare(a[:], b[:])
+	})
+
+	var buf bytes.Buffer
+	for i, sum := range hashes {
+		fmt.Fprintf(&buf, "%s:%d\r\n", bytes.ToUpper([]byte(hex.EncodeToString(sum[:]))), i+1)
+	}
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords.txt")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestCountFindsEveryHashInLargeBucket(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+
+	var hashes [][sha1.Size]byte
+	seen := map[[sha1.Size]byte]bool{}
+	add := func(prefix0, prefix1 byte) {
+		var sum [sha1.Size]byte
+		for i := range sum {
+			sum[i] = byte(rng.UintN(256))
+		}
+		sum[0], sum[1] = prefix0, prefix1
+		if !seen[sum] {
+			seen[sum] = true
+			hashes = append(hashes, sum)
+		}
+	}
+
+	// One bucket several times the scan window, between sparse neighbours.
+	for range 4000 {
+		add(0x5b, 0xa1)
+	}
+	for range 200 {
+		add(byte(rng.UintN(256)), byte(rng.UintN(256)))
+	}
+
+	path := writeCorpus(t, hashes)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() < 2*scanSize {
+		t.Fatalf("corpus is %d bytes, want a bucket larger than the scan window", info.Size())
+	}
+
+	// Open twice: once building the index, once reading it back.
+	for range 2 {
+		corpus, err := Open(path, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, sum := range hashes {
+			count, err := corpus.countHash(sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != i+1 {
+				t.Fatalf("hash %X: count = %d, want %d", sum, count, i+1)
+			}
+		}
+
+		for range 500 {
+			var sum [sha1.Size]byte
+			for i := range sum {
+				sum[i] = byte(rng.UintN(256))
+			}
+			sum[0], sum[1] = 0x5b, 0xa1
+			if seen[sum] {
+				continue
+			}
+
+			count, err := corpus.countHash(sum)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 0 {
+				t.Fatalf("hash %X: count = %d, want 0", sum, count)
+			}
+		}
+
+		corpus.Close()
+	}
+}
+
+func TestBreached(t *testing.T) {
+	hashes := [][sha1.Size]byte{
+		sha1.Sum([]byte("password")),
+		sha1.Sum([]byte("letmein")),
+	}
+	path := writeCorpus(t, hashes)
+
+	corpus, err := Open(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer corpus.Close()
+
+	for _, tt := range []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"letmein", true},
+		{"correct horse battery staple", false},
+	} {
+		count, err := corpus.Count(tt.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		breached, err := corpus.Breached(tt.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if breached != tt.want {
+			t.Errorf("Breached(%q) = %v (count %d), want %v", tt.password, breached, count, tt.want)
+		}
+	}
+}
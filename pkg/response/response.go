@@ -3,6 +3,7 @@ package response
 type ErrorDetail struct {
 	Code	string	`json:"status_code"`
 	Message	string	`json:"message"`
+	Details	any		`json:"details,omitempty"`
 }
 
 type APIResponse struct {
@@ -31,4 +32,12 @@ func BuildErrorResponse(code, message string) APIResponse {
 			Message: message,
 		},
 	}
-}
\ No newline at end of file
+}
+
+// BuildErrorResponseWithDetails adds machine-readable details, such as the
+// list of rules a password broke, to an error response.
+func BuildErrorResponseWithDetails(code, message string, details any) APIResponse {
+	res := BuildErrorResponse(code, message)
+	res.Error.Details = details
+	return res
+}
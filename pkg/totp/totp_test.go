@@ -0,0 +1,106 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of RFC 6238 appendix B, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8-digit codes; these are their last six digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeAtRFC6238(t *testing.T) {
+	for _, tt := range rfcVectors {
+		code, err := CodeAt(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != tt.code {
+			t.Errorf("code at %d = %s, want %s", tt.unix, code, tt.code)
+		}
+	}
+}
+
+func TestCodeAtAcceptsLowerCaseSecret(t *testing.T) {
+	code, err := CodeAt("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", Step(time.Unix(59, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Fatalf("code = %s, want 287082", code)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	for _, tt := range []struct {
+		offset int64
+		skew   int64
+		ok     bool
+	}{
+		{0, 0, true},
+		{-1, 0, false},
+		{-1, 1, true},
+		{1, 1, true},
+		{-2, 1, false},
+		{2, 1, false},
+		{2, 2, true},
+	} {
+		code, err := CodeAt(rfcSecret, step+tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok := Validate(rfcSecret, code, now, tt.skew)
+		if ok != tt.ok {
+			t.Errorf("code from step %+d with skew %d: ok = %v, want %v", tt.offset, tt.skew, ok, tt.ok)
+		}
+		if ok && got != step+tt.offset {
+			t.Errorf("code from step %+d: matched step %d, want %d", tt.offset, got, step+tt.offset)
+		}
+	}
+}
+
+func TestValidateRejectsBadInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "abcdef", "94287082"} {
+		if _, ok := Validate(rfcSecret, code, now, 1); ok {
+			t.Errorf("Validate accepted %q", code)
+		}
+	}
+
+	if _, ok := Validate("not base32!", "287082", now, 1); ok {
+		t.Error("Validate accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(key) != 20 {
+		t.Fatalf("secret is %d bytes, want 20", len(key))
+	}
+}
@@ -1,22 +1,89 @@
 package utils
 
 import (
-	"errors"
+	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
-func ValidatePassword(password string) error {
-	var (
-		hasMinLen	= false
-		hasUpper	= false
-		hasLower 	= false
-		hasNumber	= false
-	)
+// Rules reported in PasswordViolation.Rule.
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RuleStrength     = "strength"
+	RuleBannedWord   = "banned_word"
+	RulePersonalInfo = "personal_info"
+	RuleBreached     = "breached"
+)
+
+// PasswordPolicy is the set of rules a new password must pass. Lengths are
+// counted in characters, not bytes. MinStrength is a score from 0 to 4 as
+// returned by EstimateStrength; 0 turns the check off.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	MinStrength   int
+	BannedWords   []string
+}
+
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password broke, so a client can
+// show them all at once.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:    8,
+		MaxLength:    128,
+		RequireUpper: true,
+		RequireLower: true,
+		RequireDigit: true,
+		MinStrength:  3,
+	}
+}
 
-	if len(password) >= 8 {
-		hasMinLen = true
+// Check returns the rules password breaks. userInputs are the user's own
+// details, such as username, email and full name, which the password must
+// not contain and which count as guessable in the strength estimate.
+func (p PasswordPolicy) Check(password string, userInputs ...string) []PasswordViolation {
+	var violations []PasswordViolation
+	add := func(rule, format string, args ...any) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
 	}
 
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		add(RuleMinLength, "password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, "password must be at most %d characters long", p.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
 	for _, char := range password {
 		switch {
 		case unicode.IsUpper(char):
@@ -24,13 +91,75 @@ func ValidatePassword(password string) error {
 		case unicode.IsLower(char):
 			hasLower = true
 		case unicode.IsNumber(char):
-			hasNumber = true
+			hasDigit = true
+		case unicode.IsPunct(char) || unicode.IsSymbol(char) || unicode.IsSpace(char):
+			hasSymbol = true
 		}
 	}
 
-	if !hasMinLen || !hasUpper || !hasLower || !hasNumber {
-		return errors.New("password must be at least 8 characters long and contain at last one uppercase letter and one number")
+	if p.RequireUpper && !hasUpper {
+		add(RuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add(RuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(RuleDigit, "password must contain a number")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	normalized := unleet(strings.ToLower(password))
+	for _, word := range p.BannedWords {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" && strings.Contains(normalized, unleet(word)) {
+			add(RuleBannedWord, "password must not contain %q", word)
+		}
+	}
+
+	for _, token := range personalTokens(userInputs) {
+		if strings.Contains(normalized, token) {
+			add(RulePersonalInfo, "password must not contain your username, email or name")
+			break
+		}
+	}
+
+	if p.MinStrength > 0 && length > 0 {
+		if strength := EstimateStrength(password, userInputs...); strength.Score < p.MinStrength {
+			add(RuleStrength, "password is too easy to guess")
+		}
+	}
+
+	return violations
+}
+
+// Validate is Check returning a *PasswordPolicyError, or nil.
+func (p PasswordPolicy) Validate(password string, userInputs ...string) error {
+	if violations := p.Check(password, userInputs...); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// personalTokens splits user details into the words a password must not
+// contain. Short words are skipped; they turn up in passwords by chance.
+func personalTokens(userInputs []string) []string {
+	var tokens []string
+	for _, input := range userInputs {
+		input = strings.ToLower(input)
+		if local, _, found := strings.Cut(input, "@"); found {
+			input = local
+		}
+
+		for _, token := range strings.FieldsFunc(input, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}) {
+			if utf8.RuneCountInString(token) >= 4 {
+				tokens = append(tokens, unleet(token))
+			}
+		}
+	}
+
+	return tokens
+}
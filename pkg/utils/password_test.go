@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func rules(violations []PasswordViolation) []string {
+	names := []string{}
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+
+	return names
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	// Every character class, no strength check, so each case only breaks
+	// the rules it is about.
+	all := PasswordPolicy{MinLength: 8, MaxLength: 16, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	user := []string{"jdoe", "john.doe@example.com", "John Doe"}
+
+	for _, tt := range []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"passes", all, "Tr0ub4dor&3x", nil},
+		{"too short", all, "Ab1!", []string{RuleMinLength}},
+		{"too long", all, "Ab1!" + strings.Repeat("x", 13), []string{RuleMaxLength}},
+		{"length in characters", all, "Äb1!ééé", []string{RuleMinLength}},
+		{"multibyte at the limit", all, "Äb1!éééé", nil},
+		{"no uppercase", all, "tr0ub4dor&3x", []string{RuleUppercase}},
+		{"no lowercase", all, "TR0UB4DOR&3X", []string{RuleLowercase}},
+		{"no digit", all, "Troubador&xx", []string{RuleDigit}},
+		{"no symbol", all, "Tr0ub4dor3xx", []string{RuleSymbol}},
+		{"space is a symbol", all, "Tr0ub4dor 3x", nil},
+		{"empty", all, "", []string{RuleMinLength, RuleUppercase, RuleLowercase, RuleDigit, RuleSymbol}},
+		{"nothing required", PasswordPolicy{}, "a", nil},
+		{"banned word", PasswordPolicy{BannedWords: []string{"Acme"}}, "x-ACME-2024", []string{RuleBannedWord}},
+		{"banned word in leet", PasswordPolicy{BannedWords: []string{"acme"}}, "x-4cm3-2024", []string{RuleBannedWord}},
+		{"blank banned word", PasswordPolicy{BannedWords: []string{"  "}}, "anything", nil},
+		{"username", PasswordPolicy{}, "xx-JDoe-42", []string{RulePersonalInfo}},
+		{"email local part", PasswordPolicy{}, "john.doe!", []string{RulePersonalInfo}},
+		{"name in leet", PasswordPolicy{}, "j0hn-is-great", []string{RulePersonalInfo}},
+		{"email domain", PasswordPolicy{}, "example-pass", nil},
+		{"short name parts", PasswordPolicy{}, "doe-and-joe", nil},
+		{"weak", PasswordPolicy{MinStrength: 3}, "password1", []string{RuleStrength}},
+		{"weak with personal info", PasswordPolicy{MinStrength: 3}, "johndoe2024", []string{RulePersonalInfo, RuleStrength}},
+		{"strong", PasswordPolicy{MinStrength: 4}, "vK7#qL2!mZ9$wR", nil},
+		{"strength needs a password", PasswordPolicy{MinStrength: 3}, "", nil},
+		{"strength off", PasswordPolicy{MinStrength: 0}, "password", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(tt.policy.Check(tt.password, user...)); !slices.Equal(got, tt.want) {
+				t.Fatalf("Check(%q) broke %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	if err := policy.Validate("vK7#qL2!mZ9$wR"); err != nil {
+		t.Fatalf("Validate = %v", err)
+	}
+
+	err := policy.Validate("short")
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("Validate = %v, want a *PasswordPolicyError", err)
+	}
+
+	got := rules(policyErr.Violations)
+	for _, rule := range []string{RuleMinLength, RuleUppercase, RuleDigit, RuleStrength} {
+		if !slices.Contains(got, rule) {
+			t.Errorf("violations %v do not include %s", got, rule)
+		}
+	}
+	if !strings.Contains(err.Error(), "at least 8 characters") {
+		t.Errorf("error %q does not explain the length rule", err)
+	}
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testInnerHasher(t *testing.T) PasswordHasher {
+	t.Helper()
+
+	inner, err := NewPasswordHasher(PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return inner
+}
+
+var testPeppers = map[int][]byte{
+	1: []byte("first pepper, at least 32 bytes long"),
+	2: []byte("second pepper, at least 32 bytes long"),
+}
+
+func testPepperedHasher(t *testing.T, version int) PasswordHasher {
+	t.Helper()
+
+	hasher, err := NewPepperedHasher(testInnerHasher(t), version, testPeppers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hasher
+}
+
+func TestSplitPepper(t *testing.T) {
+	for _, tt := range []struct {
+		hash    string
+		version int
+		inner   string
+		ok      bool
+	}{
+		{"$pepper$v=1$2a$04$abc", 1, "$2a$04$abc", true},
+		{"$pepper$v=12$argon2id$v=19$m=65536", 12, "$argon2id$v=19$m=65536", true},
+		{"$2a$04$abc", 0, "", false},
+		{"$pepper$v=$2a$04$abc", 0, "", false},
+		{"$pepper$v=x$2a$04$abc", 0, "", false},
+		{"$pepper$v=1", 0, "", false},
+		{"", 0, "", false},
+	} {
+		version, inner, ok := splitPepper(tt.hash)
+		if version != tt.version || inner != tt.inner || ok != tt.ok {
+			t.Errorf("splitPepper(%q) = %d, %q, %v, want %d, %q, %v", tt.hash, version, inner, ok, tt.version, tt.inner, tt.ok)
+		}
+	}
+}
+
+func TestPepperedHashRoundTrip(t *testing.T) {
+	hasher := testPepperedHasher(t, 2)
+
+	hash, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, inner, ok := splitPepper(hash)
+	if !ok || version != 2 || !strings.HasPrefix(hash, "$pepper$v=2$2a$") {
+		t.Fatalf("hash %q does not carry pepper version 2", hash)
+	}
+
+	// The stored inner hash is of the HMAC, not of the password itself.
+	if testInnerHasher(t).Compare(inner, "correct horse battery staple") == nil {
+		t.Fatal("inner hash accepts the unpeppered password")
+	}
+
+	if err := hasher.Compare(hash, "correct horse battery staple"); err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if err := hasher.Compare(hash, "wrong"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("Compare with the wrong password = %v, want ErrPasswordMismatch", err)
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Fatal("fresh hash needs a rehash")
+	}
+}
+
+func TestPepperedCompareOlderVersions(t *testing.T) {
+	old, err := testPepperedHasher(t, 1).Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := testInnerHasher(t).Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := testPepperedHasher(t, 2)
+
+	for _, tt := range []struct {
+		name string
+		hash string
+	}{
+		{"older pepper", old},
+		{"no pepper", legacy},
+	} {
+		if err := current.Compare(tt.hash, "correct horse battery staple"); err != nil {
+			t.Errorf("%s: Compare = %v", tt.name, err)
+		}
+		if err := current.Compare(tt.hash, "wrong"); err == nil {
+			t.Errorf("%s: wrong password accepted", tt.name)
+		}
+		if !current.NeedsRehash(tt.hash) {
+			t.Errorf("%s: hash does not need a rehash after the pepper changed", tt.name)
+		}
+	}
+
+	// A retired pepper can no longer check its hashes.
+	retired, err := NewPepperedHasher(testInnerHasher(t), 2, map[int][]byte{2: testPeppers[2]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := retired.Compare(old, "correct horse battery staple"); !errors.Is(err, ErrUnknownPepper) {
+		t.Fatalf("Compare with a retired pepper = %v, want ErrUnknownPepper", err)
+	}
+}
+
+func TestPepperedNeedsRehashForInnerSettings(t *testing.T) {
+	hash, err := testPepperedHasher(t, 1).Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger, err := NewPasswordHasher(PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher, err := NewPepperedHasher(stronger, 1, testPeppers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasher.NeedsRehash(hash) {
+		t.Fatal("hash with an old bcrypt cost does not need a rehash")
+	}
+}
+
+func TestNewPepperedHasherNeedsCurrentPepper(t *testing.T) {
+	if _, err := NewPepperedHasher(testInnerHasher(t), 3, testPeppers); err == nil {
+		t.Fatal("NewPepperedHasher accepted a version with no pepper")
+	}
+}
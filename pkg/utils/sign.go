@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// SignValue appends an HMAC-SHA256 signature to value, separated by a dot.
+func SignValue(key []byte, value string) string {
+	return value + "." + signature(key, value)
+}
+
+// VerifySignedValue checks a value produced by SignValue and returns the
+// original value if the signature matches.
+func VerifySignedValue(key []byte, signed string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return "", false
+	}
+
+	value, sig := signed[:i], signed[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(signature(key, value))) {
+		return "", false
+	}
+
+	return value, true
+}
+
+func signature(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,258 @@
+package utils
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength is a rough estimate of how hard a password is to guess, in the
+// spirit of zxcvbn. Score goes from 0 (trivial) to 4 (very hard) using
+// zxcvbn's thresholds of 10^3, 10^6, 10^8 and 10^10 guesses.
+type Strength struct {
+	Score int
+	Bits  float64
+}
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonWords maps each common password or word to its rank, starting at 1.
+var commonWords = func() map[string]int {
+	words := map[string]int{}
+	for i, word := range strings.Fields(commonPasswordList) {
+		if _, found := words[word]; !found {
+			words[word] = i + 1
+		}
+	}
+	return words
+}()
+
+const (
+	// maxWordLength bounds the substrings looked up as words.
+	maxWordLength = 32
+
+	// maxEstimateLength bounds the work per password. Characters past it
+	// are priced as random.
+	maxEstimateLength = 256
+)
+
+var sequences = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"01234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+func unleet(s string) string {
+	return leetReplacer.Replace(s)
+}
+
+type strengthMatch struct {
+	start, end int
+	bits       float64
+}
+
+// EstimateStrength splits the password into the cheapest mix of common
+// words, the user's own details, keyboard or alphabet runs, repeats and
+// years, pricing anything left over as random characters. userInputs are
+// treated like the most common words.
+func EstimateStrength(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return Strength{}
+	}
+
+	charBits := math.Log2(float64(charsetSize(runes)))
+
+	extra := 0.0
+	if len(runes) > maxEstimateLength {
+		extra = float64(len(runes)-maxEstimateLength) * charBits
+		runes = runes[:maxEstimateLength]
+	}
+	n := len(runes)
+
+	lower := []rune(strings.ToLower(string(runes)))
+	if len(lower) != n {
+		lower = runes
+	}
+
+	matches := dictionaryMatches(runes, lower, personalTokens(userInputs))
+	matches = append(matches, sequenceMatches(lower)...)
+	matches = append(matches, repeatMatches(lower, charBits)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	// best[i] is the cheapest way to produce the first i characters.
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + charBits
+		for _, m := range matches {
+			// Each extra piece costs a bit for where it starts.
+			if m.end == i && best[m.start]+m.bits+1 < best[i] {
+				best[i] = best[m.start] + m.bits + 1
+			}
+		}
+	}
+
+	bits := best[n] + extra
+	score := 4
+	for i, threshold := range []float64{3, 6, 8, 10} {
+		if bits < threshold*math.Log2(10) {
+			score = i
+			break
+		}
+	}
+
+	return Strength{Score: score, Bits: bits}
+}
+
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			size += class.size
+		}
+	}
+
+	return max(size, 2)
+}
+
+func dictionaryMatches(runes, lower []rune, personal []string) []strengthMatch {
+	var matches []strengthMatch
+	n := len(lower)
+
+	for i := 0; i < n; i++ {
+		for j := i + 3; j <= n && j-i <= maxWordLength; j++ {
+			plain := string(lower[i:j])
+			word := unleet(plain)
+
+			rank, found := commonWords[word]
+			if !found {
+				for _, token := range personal {
+					if token == word {
+						rank, found = 1, true
+						break
+					}
+				}
+			}
+			if !found {
+				continue
+			}
+
+			bits := math.Log2(float64(rank + 1))
+			if word != plain {
+				bits++
+			}
+			if string(runes[i:j]) != plain {
+				bits++
+			}
+			matches = append(matches, strengthMatch{start: i, end: j, bits: bits})
+		}
+	}
+
+	return matches
+}
+
+func sequenceMatches(lower []rune) []strengthMatch {
+	var matches []strengthMatch
+	n := len(lower)
+
+	for _, seq := range sequences {
+		for _, s := range []string{seq, reverse(seq)} {
+			for i := 0; i < n; i++ {
+				j := i
+				for j < n && strings.Contains(s, string(lower[i:j+1])) {
+					j++
+				}
+				if j-i >= 3 {
+					bits := math.Log2(float64(len(s))) + math.Log2(float64(j-i))
+					matches = append(matches, strengthMatch{start: i, end: j, bits: bits})
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+// repeatMatches finds a short block written several times in a row, such
+// as "aaaa" or "abcabc". Only the first copy is paid for in full.
+func repeatMatches(lower []rune, charBits float64) []strengthMatch {
+	var matches []strengthMatch
+	n := len(lower)
+
+	for i := 0; i < n; i++ {
+		for period := 1; period <= 4 && i+2*period <= n; period++ {
+			j := i + period
+			for j < n && lower[j] == lower[j-period] {
+				j++
+			}
+
+			copies := (j - i) / period
+			if copies < 2 || (period == 1 && copies < 3) {
+				continue
+			}
+
+			end := i + copies*period
+			bits := float64(period)*charBits + math.Log2(float64(copies))
+			matches = append(matches, strengthMatch{start: i, end: end, bits: bits})
+		}
+	}
+
+	return matches
+}
+
+func yearMatches(runes []rune) []strengthMatch {
+	var matches []strengthMatch
+
+	for i := 0; i+4 <= len(runes); i++ {
+		year := string(runes[i : i+4])
+		if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) && isDigits(year) {
+			matches = append(matches, strengthMatch{start: i, end: i + 4, bits: math.Log2(200)})
+		}
+	}
+
+	return matches
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
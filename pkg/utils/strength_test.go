@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{"empty", "", nil, 0},
+		{"common password", "password", nil, 0},
+		{"common password in leet", "p@ssw0rd", nil, 0},
+		{"common password with a digit", "Password1", nil, 0},
+		{"keyboard run", "qwertyuiop", nil, 0},
+		{"alphabet run", "abcdef", nil, 0},
+		{"repeated character", "aaaaaaaaaaaa", nil, 0},
+		{"repeated block", "abcabcabcabc", nil, 1},
+		{"year", "1990", nil, 0},
+		{"word and year", "letmein2024", nil, 1},
+		{"username unknown", "johndoe", nil, 3},
+		{"username given", "johndoe", []string{"johndoe"}, 0},
+		{"email given", "johndoe", []string{"johndoe@example.com"}, 0},
+		{"random", "vK7#qL2!mZ9$wR", nil, 4},
+		{"random with user inputs", "vK7#qL2!mZ9$wR", []string{"johndoe"}, 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateStrength(tt.password, tt.userInputs...); got.Score != tt.want {
+				t.Fatalf("EstimateStrength(%q) = %d (%.1f bits), want %d", tt.password, got.Score, got.Bits, tt.want)
+			}
+		})
+	}
+}
+
+// The score follows zxcvbn's thresholds of 10^3, 10^6, 10^8 and 10^10
+// guesses.
+func TestEstimateStrengthThresholds(t *testing.T) {
+	for _, password := range []string{"a", "abc", "password", "xK9#", "letmein2024", "Tr0ub4dor&3", "johndoe", "vK7#qL2!mZ9$wR"} {
+		s := EstimateStrength(password)
+
+		want := 4
+		for i, exponent := range []float64{3, 6, 8, 10} {
+			if s.Bits < exponent*math.Log2(10) {
+				want = i
+				break
+			}
+		}
+
+		if s.Score != want {
+			t.Errorf("%q: %.1f bits scored %d, want %d", password, s.Bits, s.Score, want)
+		}
+	}
+}
+
+func TestEstimateStrengthCapitalizationCosts(t *testing.T) {
+	plain := EstimateStrength("password")
+	capitalized := EstimateStrength("Password")
+
+	if capitalized.Bits <= plain.Bits {
+		t.Fatalf("Password has %.1f bits, password %.1f; want more", capitalized.Bits, plain.Bits)
+	}
+}
+
+func TestEstimateStrengthLongInput(t *testing.T) {
+	// Past maxEstimateLength characters are priced as random rather than
+	// searched, which keeps the work per password bounded.
+	for _, password := range []string{strings.Repeat("a", 10*maxEstimateLength), strings.Repeat("vK7#", 10*maxEstimateLength)} {
+		s := EstimateStrength(password)
+		if s.Score < 0 || s.Score > 4 || math.IsNaN(s.Bits) {
+			t.Fatalf("strength of a %d character password = %+v", len(password), s)
+		}
+	}
+}